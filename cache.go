@@ -0,0 +1,158 @@
+package ignore
+
+import (
+	"regexp"
+	"sync"
+)
+
+// lazyRegexp is a compiled pattern. Patterns compiled with Options.Lazy
+// only hold the text of their regexp until the first match, and then look
+// it up in the shared cache.
+type lazyRegexp struct {
+	expr string
+	once sync.Once
+	re   *regexp.Regexp
+}
+
+// get returns the compiled regexp, compiling it on the first call
+func (l *lazyRegexp) get() *regexp.Regexp {
+	l.once.Do(func() {
+		if l.re == nil {
+			l.re = sharedRegexp(l.expr)
+		}
+	})
+	return l.re
+}
+
+// neverMatches stands in for a lazy pattern which cannot be compiled. The
+// regexps of lazy patterns are parsed when the set is compiled, so this is
+// only a guard.
+var neverMatches = regexp.MustCompile(`[^\x00-\x{10FFFF}]`)
+
+// sharedCache holds the regexps of the lazy patterns of all sets, keyed by
+// their text, so that a pattern shared by many sets is compiled once.
+// Entries are never evicted: the distinct patterns of a process are few.
+var sharedCache = struct {
+	sync.Mutex
+	regexps map[string]*regexp.Regexp
+	stats   CacheStats
+}{regexps: make(map[string]*regexp.Regexp)}
+
+// sharedRegexp returns the compiled regexp "expr" from the shared cache,
+// compiling it on a miss
+func sharedRegexp(expr string) *regexp.Regexp {
+	sharedCache.Lock()
+	defer sharedCache.Unlock()
+
+	if re, ok := sharedCache.regexps[expr]; ok {
+		sharedCache.stats.Hits++
+		return re
+	}
+	sharedCache.stats.Misses++
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		re = neverMatches
+	}
+	sharedCache.regexps[expr] = re
+	sharedCache.stats.Size = len(sharedCache.regexps)
+	return re
+}
+
+// CacheStats are the metrics of the regexp cache shared by the sets
+// compiled with Options.Lazy
+type CacheStats struct {
+	Hits   uint64 // Lazy patterns whose regexp was already compiled
+	Misses uint64 // Lazy patterns whose regexp had to be compiled
+	Size   int    // Distinct regexps held by the cache
+}
+
+// SharedCacheStats returns the metrics of the regexp cache shared by the
+// sets compiled with Options.Lazy. A lazy pattern counts once, on its first
+// use.
+func SharedCacheStats() CacheStats {
+	sharedCache.Lock()
+	defer sharedCache.Unlock()
+	return sharedCache.stats
+}
+
+// CachedIgnore wraps a GitIgnore and memoizes its results by path, for
+// callers which query the same paths over and over. The cache is not
+// aware of changes to the rules of the wrapped set, such as by ReloadFile
+// or AddPattern: call InvalidateCache after making them. A CachedIgnore is
+// safe for concurrent use.
+type CachedIgnore struct {
+	g            *GitIgnore
+	mu           sync.RWMutex
+	results      map[string]int // Results of matchesPath by path
+	hierarchical map[string]int // Results of hierarchicalResult by path
+	generation   int            // Counts the calls to InvalidateCache
+}
+
+// WithCache returns a CachedIgnore memoizing the results of g
+func (g *GitIgnore) WithCache() *CachedIgnore {
+	return &CachedIgnore{
+		g:            g,
+		results:      make(map[string]int),
+		hierarchical: make(map[string]int),
+	}
+}
+
+// lookup returns the result for "f" from the cache of matchesPath, or of
+// hierarchicalResult if "hierarchical" is true, computing and storing it on
+// a miss
+func (c *CachedIgnore) lookup(f string, hierarchical bool) int {
+	c.mu.RLock()
+	results, generation := c.results, c.generation
+	if hierarchical {
+		results = c.hierarchical
+	}
+	result, ok := results[f]
+	c.mu.RUnlock()
+	if ok {
+		return result
+	}
+
+	if hierarchical {
+		result = c.g.hierarchicalResult(f)
+	} else {
+		result = c.g.matchesPath(f)
+	}
+	c.mu.Lock()
+	// A result computed before InvalidateCache may be stale
+	if generation == c.generation {
+		results[f] = result
+	}
+	c.mu.Unlock()
+	return result
+}
+
+// Result returns Match, NonMatch or Negation for the path "f", like
+// GitIgnore.MatchesPathHow does
+func (c *CachedIgnore) Result(f string) int {
+	return c.lookup(f, false)
+}
+
+// MatchesPath works like GitIgnore.MatchesPath
+func (c *CachedIgnore) MatchesPath(f string) bool {
+	return c.Result(f) != NonMatch
+}
+
+// IgnoresPath works like GitIgnore.IgnoresPath
+func (c *CachedIgnore) IgnoresPath(f string) bool {
+	return c.lookup(f, true) == Match
+}
+
+// IncludesPath works like GitIgnore.IncludesPath
+func (c *CachedIgnore) IncludesPath(f string) bool {
+	return c.lookup(f, true) == Negation
+}
+
+// InvalidateCache drops the memoized results, so that later queries see
+// the current rules of the wrapped set
+func (c *CachedIgnore) InvalidateCache() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = make(map[string]int)
+	c.hierarchical = make(map[string]int)
+	c.generation++
+}
@@ -0,0 +1,179 @@
+// Implement tests for the caches of the `ignore` library
+package ignore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate that "Options.Lazy" matches like the default and compiles on
+// first use only
+func TestCompileIgnoreLines_Lazy(test *testing.T) {
+	lines := []string{"*.lazy-a", "/build-lazy-a/", "!keep.lazy-a", "docs/**/*.lazy-b"}
+	eager, error := CompileIgnoreLines(lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	before := SharedCacheStats()
+	object, error := CompileIgnoreLinesWithOptions(Options{Lazy: true}, lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, before, SharedCacheStats(), "nothing should be compiled before the first match")
+
+	for _, f := range []string{"a.lazy-a", "x/a.lazy-a", "keep.lazy-a", "build-lazy-a/", "x/build-lazy-a/", "docs/a.lazy-b", "docs/x/y/a.lazy-b", "a.lazy-b"} {
+		assert.Equal(test, eager.matchesPath(f), object.matchesPath(f), f+" should match like the eager set")
+	}
+	after := SharedCacheStats()
+	assert.Equal(test, before.Misses+4, after.Misses, "each pattern should be compiled once")
+	assert.Equal(test, before.Hits, after.Hits, "a set looks its patterns up once")
+	assert.Equal(test, before.Size+4, after.Size, "the cache should hold the patterns")
+
+	// Another set with the same patterns reuses the regexps
+	other, _ := CompileIgnoreLinesWithOptions(Options{Lazy: true}, lines...)
+	assert.Equal(test, Match, other.matchesPath("a.lazy-a"), "a.lazy-a should match")
+	assert.Equal(test, Negation, other.matchesPath("keep.lazy-a"), "keep.lazy-a should be re-included")
+	assert.Equal(test, after.Misses, SharedCacheStats().Misses, "nothing should be compiled again")
+	assert.True(test, SharedCacheStats().Hits > after.Hits, "the patterns should be cache hits")
+
+	// The options are part of the regexps
+	folded, _ := CompileIgnoreLinesWithOptions(Options{Lazy: true, CaseInsensitive: true}, lines...)
+	assert.Equal(test, Match, folded.matchesPath("A.LAZY-A"), "A.LAZY-A should match")
+	assert.Equal(test, NonMatch, object.matchesPath("A.LAZY-A"), "A.LAZY-A should not match case-sensitively")
+
+	// A malformed line is reported as without the option
+	_, eagerError := CompileIgnoreLines(`x\`)
+	_, error = CompileIgnoreLinesWithOptions(Options{Lazy: true}, `x\`)
+	assert.NotNil(test, error, `x\ should be reported`)
+	assert.Equal(test, eagerError, error, `x\ should be reported as by CompileIgnoreLines`)
+}
+
+// Validate the first use of lazy patterns shared by several sets from many
+// goroutines, run with -race
+func TestCompileIgnoreLines_LazyConcurrent(test *testing.T) {
+	lines := []string{"*.lazy-race", "/out-lazy-race/", "!keep.lazy-race"}
+	before := SharedCacheStats()
+
+	sets := make([]*GitIgnore, 8)
+	for i := range sets {
+		sets[i], _ = CompileIgnoreLinesWithOptions(Options{Lazy: true}, lines...)
+	}
+	merged := Merge(sets[0], sets[1])
+
+	var wg sync.WaitGroup
+	results := make([][]int, 32)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			object := sets[i%len(sets)]
+			if i%5 == 0 {
+				object = merged
+			}
+			for _, f := range []string{"a.lazy-race", "keep.lazy-race", "out-lazy-race/x", "src/main.go"} {
+				results[i] = append(results[i], object.matchesPath(f))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range results {
+		assert.Equal(test, []int{Match, Negation, Match, NonMatch}, results[i], fmt.Sprintf("goroutine %d should see the same results", i))
+	}
+	assert.Equal(test, before.Misses+uint64(len(lines)), SharedCacheStats().Misses, "each pattern should be compiled once in all")
+}
+
+// Benchmark compiling many lazy sets sharing their patterns and matching
+// a path against each, as for many repositories with similar ignore files
+func BenchmarkCompileIgnoreLines_Lazy(bench *testing.B) {
+	benchmarkManySets(bench, Options{Lazy: true})
+}
+
+// Benchmark the same with eagerly compiled sets, for comparison
+func BenchmarkCompileIgnoreLines_Eager(bench *testing.B) {
+	benchmarkManySets(bench, Options{})
+}
+
+func benchmarkManySets(bench *testing.B, opts Options) {
+	lines := []string{"*.log", "/build/", "**/node_modules/", "!keep.log", "*.tmp", "/dist", "vendor/**/*.o", ".idea/"}
+	bench.ReportAllocs()
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		for repo := 0; repo < 100; repo++ {
+			object, _ := CompileIgnoreLinesWithOptions(opts, lines...)
+			object.matchesPath("src/main.go")
+		}
+	}
+}
+
+// Validate that "CachedIgnore" returns the results of the wrapped set
+func TestCachedIgnore(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	cached := object.WithCache()
+	var _ IgnoreParser = cached
+
+	paths := []string{"a.log", "keep.log", "build/", "build/keep.log", "src/main.go", "src/keep.log"}
+	for round := 0; round < 2; round++ {
+		for _, f := range paths {
+			assert.Equal(test, object.matchesPath(f), cached.Result(f), "unexpected result for "+f)
+			assert.Equal(test, object.MatchesPath(f), cached.MatchesPath(f), "unexpected MatchesPath for "+f)
+			assert.Equal(test, object.IgnoresPath(f), cached.IgnoresPath(f), "unexpected IgnoresPath for "+f)
+			assert.Equal(test, object.IncludesPath(f), cached.IncludesPath(f), "unexpected IncludesPath for "+f)
+		}
+	}
+
+	// Results are kept until the cache is invalidated
+	assert.Nil(test, object.AddPattern("*.go"), "error from AddPattern should be nil")
+	assert.Equal(test, NonMatch, cached.Result("src/main.go"), "the cached result should be returned")
+	assert.False(test, cached.IgnoresPath("src/main.go"), "the cached result should be returned")
+	cached.InvalidateCache()
+	assert.Equal(test, Match, cached.Result("src/main.go"), "the new rules should apply")
+	assert.True(test, cached.IgnoresPath("src/main.go"), "the new rules should apply")
+}
+
+// Validate concurrent queries and invalidations, run with -race
+func TestCachedIgnore_Concurrent(test *testing.T) {
+	object, _ := CompileIgnoreLines("*.log", "!keep.log")
+	cached := object.WithCache()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				f := fmt.Sprintf("dir%d/file%d.log", i, j%10)
+				assert.True(test, cached.IgnoresPath(f), f+" should be ignored")
+				assert.Equal(test, Negation, cached.Result(fmt.Sprintf("dir%d/keep.log", j)), "keep.log should be re-included")
+				if j%25 == 0 {
+					cached.InvalidateCache()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Benchmark repeated queries of the same paths, without and with a cache
+func BenchmarkMatchesPath_Repeated(bench *testing.B) {
+	object, _ := CompileIgnoreLines("*.log", "build/", "**/tmp", "/vendor", "src/*.go", "!keep.log", "docs/**/*.html")
+	benchmarkRepeated(bench, object)
+}
+
+func BenchmarkCachedIgnore_Repeated(bench *testing.B) {
+	object, _ := CompileIgnoreLines("*.log", "build/", "**/tmp", "/vendor", "src/*.go", "!keep.log", "docs/**/*.html")
+	benchmarkRepeated(bench, object.WithCache())
+}
+
+func benchmarkRepeated(bench *testing.B, object IgnoreParser) {
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("src/pkg%d/sub/file%d.go", i%50, i)
+	}
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		for _, f := range paths {
+			object.MatchesPath(f)
+		}
+	}
+}
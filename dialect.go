@@ -0,0 +1,116 @@
+package ignore
+
+import (
+	"path"
+	"strings"
+)
+
+// Dialect is a flavour of the ignore file format, see Options.ConvertFrom
+type Dialect int
+
+const (
+	Git    Dialect = iota // .gitignore, the default
+	Docker                // .dockerignore
+	Npm                   // .npmignore
+)
+
+// npmDefaults lists the paths npm leaves out of a package regardless of
+// what its .npmignore says
+var npmDefaults = []string{
+	".git",
+	".svn",
+	".hg",
+	"CVS",
+	".lock-wscript",
+	".wafpickle-*",
+	".*.swp",
+	".DS_Store",
+	"._*",
+	"npm-debug.log",
+	".npmrc",
+	"node_modules",
+	"config.gypi",
+	"*.orig",
+	"package-lock.json",
+}
+
+// npmIgnore holds the compiled npmDefaults
+var npmIgnore, _ = CompileIgnoreLines(npmDefaults...)
+
+// hasDefaults returns true if the dialect ignores some paths by default
+func (d Dialect) hasDefaults() bool {
+	return d == Npm
+}
+
+// evaluateDefaults returns Match if the dialect ignores the relative path
+// "f" by default, before any rule is applied, and NonMatch otherwise
+func (d Dialect) evaluateDefaults(f string, isDir bool, stats *MatchStats) int {
+	if d != Npm {
+		return NonMatch
+	}
+	if result, _ := npmIgnore.evaluate(f, isDir, stats); result != Match {
+		return NonMatch
+	}
+	return Match
+}
+
+// convert rewrites a line of the dialect into the equivalent gitignore
+// line. A .dockerignore pattern always matches from the root of the build
+// context, and a trailing slash does not restrict it to directories.
+func (d Dialect) convert(line string) string {
+	if d != Docker {
+		return line
+	}
+	// Docker also strips leading spaces, but only after detecting comments
+	if strings.HasPrefix(line, "#") {
+		return line
+	}
+	body := strings.TrimSpace(line)
+	if body == "" {
+		return ""
+	}
+	negation := ""
+	if strings.HasPrefix(body, "!") {
+		negation, body = "!", body[1:]
+	}
+	body = path.Clean("/" + body)
+	if body == "/" {
+		return ""
+	}
+	return negation + body
+}
+
+// CompositeMatcher combines sets which may be written in different
+// dialects, such as the .gitignore and the .dockerignore of a project, for
+// tools which have to respect all of them. A path is ignored if any member
+// ignores it, each member applying its own negations only.
+type CompositeMatcher struct {
+	members []*GitIgnore
+}
+
+// NewCompositeMatcher returns a CompositeMatcher of the given sets, which
+// are consulted in order
+func NewCompositeMatcher(members ...*GitIgnore) *CompositeMatcher {
+	return &CompositeMatcher{members: members}
+}
+
+// IgnoresPath returns true if any member ignores the path "f", see
+// GitIgnore.IgnoresPath
+func (c *CompositeMatcher) IgnoresPath(f string) bool {
+	member, _ := c.IgnoredByWhichDialect(f)
+	return member != nil
+}
+
+// IgnoredByWhichDialect returns the first member which ignores the path
+// "f" together with the dialect it was compiled from, or nil if no member
+// ignores it
+func (c *CompositeMatcher) IgnoredByWhichDialect(f string) (*GitIgnore, Dialect) {
+	for _, member := range c.members {
+		if member.IgnoresPath(f) {
+			member.mu.RLock()
+			defer member.mu.RUnlock()
+			return member, member.opts.ConvertFrom
+		}
+	}
+	return nil, Git
+}
@@ -0,0 +1,110 @@
+// Implement tests for the dialects of the `ignore` library
+package ignore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate the differences between the dialects on the same lines
+func TestConvertFrom(test *testing.T) {
+	lines := []string{"foo", "build/", "**/*.tmp", "!/foo/keep"}
+	git, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Git}, lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	docker, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Docker}, lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	npm, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Npm}, lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+
+	// Shared semantics
+	for _, object := range []*GitIgnore{git, docker, npm} {
+		assert.Equal(test, Match, object.matchesPath("foo"), "foo should match")
+		assert.Equal(test, Match, object.matchesPath("foo/bar"), "foo/bar should match")
+		assert.Equal(test, Negation, object.matchesPath("foo/keep"), "foo/keep should be negated")
+		assert.Equal(test, Match, object.matchesPath("build/"), "build/ should match")
+		assert.Equal(test, Match, object.matchesPath("a/b/c.tmp"), "**/*.tmp should match in all directories")
+	}
+
+	// Docker patterns always match from the root
+	assert.Equal(test, Match, git.matchesPath("src/foo"), "git should match src/foo")
+	assert.Equal(test, NonMatch, docker.matchesPath("src/foo"), "docker should not match src/foo")
+
+	// Docker patterns ignore files too despite a trailing slash
+	assert.Equal(test, NonMatch, git.matchesPath("build"), "git should not match the build file")
+	assert.Equal(test, Match, docker.matchesPath("build"), "docker should match the build file")
+
+	// Npm leaves out its defaults
+	assert.Equal(test, NonMatch, git.matchesPath("node_modules/x"), "git should not match node_modules")
+	assert.Equal(test, Match, npm.matchesPath("node_modules/x"), "npm should match node_modules")
+	assert.Equal(test, Match, npm.matchesPath("lib/.npmrc"), "npm should match .npmrc")
+	assert.Equal(test, len(lines), len(npm.Rules()), "npm defaults should not be listed as rules")
+}
+
+// Validate that npm defaults do not take rule indices
+func TestConvertFrom_NpmDefaultsAreNotRules(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Npm}, "*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+
+	assert.Equal(test, []string{"*.log"}, object.SortedPatterns(), "only the line should be a pattern")
+	assert.Equal(test, []string{"*.log"}, object.NormalizedRules(), "only the line should be a rule")
+	assert.Equal(test, map[int][]string{-1: {"node_modules"}, 0: {"a.log"}}, object.GroupByDecidingRule([]string{"node_modules", "a.log"}), "defaults should be decided by no rule")
+	assert.Equal(test, IgnoredAsFile, object.IgnoreKind("node_modules", true), "node_modules should be ignored")
+	assert.True(test, object.HasMatchesUnder("src"), "defaults can match anywhere")
+
+	result, error := object.MatchesPathReordered("node_modules", []int{0})
+	assert.Nil(test, error, "error from MatchesPathReordered should be nil")
+	assert.Equal(test, Match, result, "defaults should apply to a reordering")
+
+	merged := Merge(object, object)
+	assert.Equal(test, []string{"*.log", "*.log"}, merged.SortedPatterns(), "defaults should not be merged")
+	assert.True(test, merged.IgnoresPath("node_modules/x"), "the merged set should keep the defaults")
+}
+
+// Validate the normalization of .dockerignore paths
+func TestConvertFrom_DockerPaths(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Docker}, "./a/../b", "/c", "/", "# d")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, []string{"/b", "/c"}, object.SortedPatterns(), "paths should be cleaned and anchored")
+	assert.Equal(test, NonMatch, object.matchesPath("x/b"), "b should only match from the root")
+}
+
+// Validate that npm defaults can be re-included and do not shift line numbers
+func TestConvertFrom_NpmDefaults(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Npm}, "!.npmrc")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, Negation, object.matchesPath(".npmrc"), ".npmrc should be re-included")
+
+	_, error = CompileIgnoreLinesWithOptions(Options{ConvertFrom: Npm}, "*.log", "a**b")
+	assert.EqualError(test, error, `line 2: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+}
+
+// Validate "CompositeMatcher" with a git and a docker set
+func TestCompositeMatcher(test *testing.T) {
+	git, error := CompileIgnoreLines("*.log", "!keep.log", "secret.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	docker, error := CompileIgnoreLinesWithOptions(Options{ConvertFrom: Docker}, "build", "*.md", "!README.md", "!secret.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	object := NewCompositeMatcher(git, docker)
+
+	for _, f := range []string{"main.go", "keep.log", "README.md", "x/build", "x/notes.md"} {
+		assert.False(test, object.IgnoresPath(f), f+" should not be ignored")
+		member, _ := object.IgnoredByWhichDialect(f)
+		assert.Nil(test, member, f+" should be ignored by no member")
+	}
+	for f, want := range map[string]*GitIgnore{
+		"a.log":       git,
+		"x/a.log":     git,
+		"build":       docker,
+		"build/x.o":   docker,
+		"notes.md":    docker,
+		"secret.txt":  git, // A member cannot re-include what another ignores
+		"build/a.log": git,
+	} {
+		assert.True(test, object.IgnoresPath(f), f+" should be ignored")
+		member, dialect := object.IgnoredByWhichDialect(f)
+		assert.True(test, member == want, f+" should be ignored by the expected member")
+		assert.Equal(test, want.opts.ConvertFrom, dialect, f+" should report the dialect of the member")
+	}
+	assert.False(test, NewCompositeMatcher().IgnoresPath("a.log"), "an empty matcher ignores nothing")
+}
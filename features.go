@@ -0,0 +1,51 @@
+package ignore
+
+// Features describes which parts of the gitignore format, and which
+// extensions to it, are implemented by this version of the library
+type Features struct {
+	// Standard gitignore behaviour
+	Comments       bool // "#" comments and "\#" escapes [Rule 2]
+	EscapedSpaces  bool // Trailing spaces kept when escaped with "\" [Rule 3]
+	Negation       bool // "!" re-includes a path, "\!" escapes it [Rule 4]
+	DirOnly        bool // A trailing "/" matches directories only [Rule 5]
+	Anchoring      bool // Patterns with a slash are anchored to the base path [Rules 6-8]
+	DoubleStar     bool // Leading, trailing and middle "**" [Rule 9]
+	QuestionMark   bool // The "?" wildcard
+	BracketClasses bool // Bracket expressions such as "[a-z]" and "[!abc]"
+
+	// Extensions which have to be enabled in Options
+	AnchorEnd       bool // A trailing "$" forbids matching descendants
+	ExpandEnv       bool // Environment variables are expanded in patterns
+	RegexpFlags     bool // Go regexp flags are applied to every pattern
+	CaseInsensitive bool // Patterns match regardless of case
+	Dialects        bool // Lines of .dockerignore and .npmignore files are converted
+	Lazy            bool // Patterns are compiled on first use through a shared cache
+	BangBang        bool // A leading "!!" stands for a literal "!"
+
+	NormalizeUnicode bool // Patterns and paths are normalized by a caller-supplied function
+}
+
+// SupportedFeatures returns the features implemented by this version of
+// the library, so that callers can feature-detect at runtime
+func SupportedFeatures() Features {
+	return Features{
+		Comments:       true,
+		EscapedSpaces:  true,
+		Negation:       true,
+		DirOnly:        true,
+		Anchoring:      true,
+		DoubleStar:     true,
+		QuestionMark:   true,
+		BracketClasses: true,
+
+		AnchorEnd:       true,
+		ExpandEnv:       true,
+		RegexpFlags:     true,
+		CaseInsensitive: true,
+		Dialects:        true,
+		Lazy:            true,
+		BangBang:        true,
+
+		NormalizeUnicode: true,
+	}
+}
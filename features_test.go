@@ -0,0 +1,30 @@
+// Implement tests for the feature detection of the `ignore` library
+package ignore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate "SupportedFeatures()"
+func TestSupportedFeatures(test *testing.T) {
+	features := SupportedFeatures()
+
+	assert.True(test, features.Comments, "comments are supported")
+	assert.True(test, features.EscapedSpaces, "escaped trailing spaces are supported")
+	assert.True(test, features.Negation, "negation is supported")
+	assert.True(test, features.DirOnly, "directory-only patterns are supported")
+	assert.True(test, features.Anchoring, "anchoring of patterns with a slash is supported")
+	assert.True(test, features.DoubleStar, "** is supported")
+	assert.True(test, features.QuestionMark, "? is supported")
+	assert.True(test, features.BracketClasses, "bracket expressions are supported")
+	assert.True(test, features.AnchorEnd, "the AnchorEnd extension is supported")
+	assert.True(test, features.ExpandEnv, "the ExpandEnv extension is supported")
+	assert.True(test, features.RegexpFlags, "the RegexpFlags extension is supported")
+	assert.True(test, features.CaseInsensitive, "the CaseInsensitive extension is supported")
+	assert.True(test, features.Dialects, "the ConvertFrom extension is supported")
+	assert.True(test, features.Lazy, "the Lazy option is supported")
+	assert.True(test, features.BangBang, "the BangBang extension is supported")
+	assert.True(test, features.NormalizeUnicode, "the NormalizeUnicode option is supported")
+}
@@ -0,0 +1,138 @@
+package ignore
+
+import (
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// FilterChan returns a channel which emits the paths received from "in"
+// as they arrive, passing through only the ignored paths if "keepIgnored"
+// is true, or only the paths which are not ignored otherwise. The output
+// channel is closed once "in" is closed.
+func (g *GitIgnore) FilterChan(in <-chan string, keepIgnored bool) <-chan string {
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		for f := range in {
+			if g.IgnoresPath(f) == keepIgnored {
+				out <- f
+			}
+		}
+	}()
+	return out
+}
+
+// FilterChildren returns the names among "childNames", the entries of the
+// directory "parent", which are not ignored, in order. "childIsDir" tells
+// which entries are directories, so that directory-only patterns [Rule 5]
+// apply to them only; entries past its end are taken to be files. The
+// parent itself is assumed not to be ignored, which is what a walker calling
+// this for every directory it descends into ensures.
+func (g *GitIgnore) FilterChildren(parent string, childNames []string, childIsDir []bool) []string {
+	var kept []string
+	for i, name := range childNames {
+		isDir := i < len(childIsDir) && childIsDir[i]
+		rel, _ := g.relPath(filepath.Join(parent, name))
+		if g.matchesRelPath(rel, isDir) != Match {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// batchChunk is the smallest number of paths MatchesPathBatch hands to a
+// goroutine of its own, below which the overhead is not worth it
+const batchChunk = 512
+
+// MatchesPathBatch returns Match, NonMatch or Negation for each of "paths",
+// in order, as MatchesPathHow would. Large inputs are matched in parallel
+// by up to runtime.NumCPU() goroutines.
+func (g *GitIgnore) MatchesPathBatch(paths []string) []int {
+	results := make([]int, len(paths))
+	workers := runtime.NumCPU()
+	if n := (len(paths) + batchChunk - 1) / batchChunk; n < workers {
+		workers = n
+	}
+	if workers <= 1 {
+		for i, f := range paths {
+			results[i] = g.matchesPath(f)
+		}
+		return results
+	}
+
+	// Each goroutine fills in its own slice of the results
+	var wg sync.WaitGroup
+	size := (len(paths) + workers - 1) / workers
+	for start := 0; start < len(paths); start += size {
+		end := start + size
+		if end > len(paths) {
+			end = len(paths)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				results[i] = g.matchesPath(paths[i])
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return results
+}
+
+// GroupByDecidingRule groups "paths" by the index of the pattern deciding
+// whether they are ignored or re-included, which is the last pattern
+// targeting them. Paths which no pattern decides, including those only
+// ignored by the defaults of the ConvertFrom dialect, are grouped under -1.
+func (g *GitIgnore) GroupByDecidingRule(paths []string) map[int][]string {
+	groups := make(map[int][]string)
+	for _, f := range paths {
+		rel, isDir := g.relPath(f)
+		_, idx := g.evaluate(rel, isDir, nil)
+		groups[idx] = append(groups[idx], f)
+	}
+	return groups
+}
+
+// UnusedPatterns returns the indices of the patterns which are not the
+// deciding match for any of "paths", in order, to help prune dead rules
+// from an ignore file. A pattern which matches some path but is always
+// overridden by a later one is unused as well.
+func (g *GitIgnore) UnusedPatterns(paths []string) []int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	used := make([]bool, len(g.patterns))
+	for _, f := range paths {
+		rel, isDir := g.relPath(f)
+		if _, idx := g.evaluateLocked(rel, isDir, nil); idx >= 0 {
+			used[idx] = true
+		}
+	}
+	var unused []int
+	for idx, ok := range used {
+		if !ok {
+			unused = append(unused, idx)
+		}
+	}
+	return unused
+}
+
+// DiffIgnored compares how the sets "old" and "new" classify "paths", to
+// show the impact of editing an ignore file. It returns the paths which
+// "new" ignores but "old" does not, and those which "old" ignores but "new"
+// does not, each in the order of "paths". A path is ignored as decided by
+// IgnoresPath.
+func DiffIgnored(old, new *GitIgnore, paths []string) (nowIgnored, nowIncluded []string) {
+	for _, f := range paths {
+		before, after := old.IgnoresPath(f), new.IgnoresPath(f)
+		switch {
+		case after && !before:
+			nowIgnored = append(nowIgnored, f)
+		case before && !after:
+			nowIncluded = append(nowIncluded, f)
+		}
+	}
+	return nowIgnored, nowIncluded
+}
@@ -0,0 +1,152 @@
+// Implement tests for the path filtering helpers of the `ignore` library
+package ignore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to feed "paths" through "FilterChan()" and collect the output
+func collectFilterChan(object *GitIgnore, keepIgnored bool, paths ...string) []string {
+	in := make(chan string)
+	go func() {
+		for _, f := range paths {
+			in <- f
+		}
+		close(in)
+	}()
+
+	var out []string
+	for f := range object.FilterChan(in, keepIgnored) {
+		out = append(out, f)
+	}
+	return out
+}
+
+// Validate "FilterChan()"
+func TestFilterChan(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	paths := []string{"a.log", "keep.log", "main.go", "build/x.o", "src/b.log", "src/util.go"}
+	assert.Equal(test, []string{"keep.log", "main.go", "src/util.go"}, collectFilterChan(object, false, paths...), "unexpected kept paths")
+	assert.Equal(test, []string{"a.log", "build/x.o", "src/b.log"}, collectFilterChan(object, true, paths...), "unexpected ignored paths")
+	assert.Nil(test, collectFilterChan(object, false), "an empty input should produce no output")
+}
+
+// Validate "GroupByDecidingRule()"
+func TestGroupByDecidingRule(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep*.log", "build/", "!*.md")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	groups := object.GroupByDecidingRule([]string{"a.log", "keep.log", "build/x.o", "keep2.log", "main.go", "README.md", "b.log"})
+	assert.Equal(test, map[int][]string{
+		0:  {"a.log", "b.log"},
+		1:  {"keep.log", "keep2.log"},
+		2:  {"build/x.o"},
+		-1: {"main.go", "README.md"},
+	}, groups, "unexpected grouping")
+}
+
+// Validate "FilterChildren()"
+func TestFilterChildren(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "build/", "!keep.log", "/src/gen")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	names := []string{"main.go", "a.log", "keep.log", "build", "build.go", "gen", "util"}
+	isDir := []bool{false, false, false, true, false, true, true}
+	assert.Equal(test, []string{"main.go", "keep.log", "build.go", "util"}, object.FilterChildren("src", names, isDir), "unexpected children of src")
+
+	// Directory-only patterns only prune directories
+	isDir[3] = false
+	assert.Equal(test, []string{"main.go", "keep.log", "build", "build.go", "util"}, object.FilterChildren("src", names, isDir), "the build file should be kept")
+
+	// Anchored patterns depend on the parent
+	assert.Equal(test, []string{"gen"}, object.FilterChildren("lib", []string{"gen"}, []bool{true}), "lib/gen should be kept")
+	assert.Equal(test, []string{"x"}, object.FilterChildren("src", []string{"x", "y.log"}, nil), "missing flags should mean files")
+}
+
+// Validate that listing the root marks a directory entry itself ignored by
+// a directory-only pattern [Rule 5], not only its contents
+func TestFilterChildren_DirOnlyEntry(test *testing.T) {
+	object, error := CompileIgnoreLines("build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	names := []string{"build", "main.go", "src"}
+	isDir := []bool{true, false, true}
+	for _, root := range []string{"", "."} {
+		assert.Equal(test, []string{"main.go", "src"}, object.FilterChildren(root, names, isDir), "the build entry should be ignored in "+root)
+	}
+	assert.Equal(test, []string{"main.go"}, object.FilterChildren("src", []string{"build", "main.go"}, []bool{true, false}), "src/build should be ignored")
+
+	// The same holds for a set compiled from a file in the listed directory
+	writeFileToTestDir("repo/.gitignore", "build/\n")
+	defer cleanupTestDir()
+	object, error = CompileIgnoreFile("./test_fixtures/repo/.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+	assert.Equal(test, []string{"main.go", "src"}, object.FilterChildren("test_fixtures/repo", names, isDir), "the build entry should be ignored")
+	assert.True(test, object.MatchesPathIsDir("test_fixtures/repo/build", true), "the build directory should match")
+}
+
+// Validate that "MatchesPathBatch()" matches like "MatchesPathHow()"
+func TestMatchesPathBatch(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "build/", "/dist", "docs/**/*.html")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, []int{}, object.MatchesPathBatch(nil), "an empty input should give no results")
+	assert.Equal(test, []int{Negation}, object.MatchesPathBatch([]string{"src/keep.log"}), "unexpected result for a single path")
+
+	// Enough paths to be matched in parallel
+	names := []string{"a.log", "keep.log", "build/", "build/x.o", "dist", "main.go", "docs/a.html", "docs/x/b.html"}
+	var paths []string
+	for i := 0; len(paths) < 5*batchChunk+3; i++ {
+		for _, name := range names {
+			paths = append(paths, fmt.Sprintf("dir%d/%s", i%3, name), name)
+		}
+	}
+	for _, batch := range [][]string{paths[:len(names)], paths} {
+		results := object.MatchesPathBatch(batch)
+		assert.Equal(test, len(batch), len(results), "there should be one result per path")
+		for i, f := range batch {
+			result, _, _ := object.MatchesPathHow(f)
+			assert.Equal(test, result, results[i], "unexpected result for "+f)
+		}
+	}
+}
+
+// Validate "UnusedPatterns()"
+func TestUnusedPatterns(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "debug.log", "!keep.log", "*.tmp", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	paths := []string{"a.log", "debug.log", "keep.log", "build/x.o", "main.go"}
+	assert.Equal(test, []int{3}, object.UnusedPatterns(paths), "*.tmp decides no path")
+
+	// A pattern which always loses to a later one is unused too
+	assert.Equal(test, []int{0, 2, 3, 4}, object.UnusedPatterns([]string{"debug.log"}), "only debug.log should be used")
+	assert.Nil(test, object.UnusedPatterns(append(paths, "a.tmp")), "every pattern should be used")
+	assert.Equal(test, []int{0, 1, 2, 3, 4}, object.UnusedPatterns(nil), "an empty corpus uses nothing")
+}
+
+// Validate "DiffIgnored()"
+func TestDiffIgnored(test *testing.T) {
+	old, error := CompileIgnoreLines("*.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	new, error := CompileIgnoreLines("*.log", "!keep.log", "*.tmp")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	paths := []string{"a.log", "keep.log", "src/keep.log", "a.tmp", "build/", "build/x.o", "main.go"}
+	nowIgnored, nowIncluded := DiffIgnored(old, new, paths)
+	assert.Equal(test, []string{"a.tmp"}, nowIgnored, "unexpected newly ignored paths")
+	assert.Equal(test, []string{"keep.log", "src/keep.log", "build/", "build/x.o"}, nowIncluded, "unexpected newly included paths")
+
+	// The comparison is directed, and identical sets change nothing
+	nowIgnored, nowIncluded = DiffIgnored(new, old, paths)
+	assert.Equal(test, []string{"keep.log", "src/keep.log", "build/", "build/x.o"}, nowIgnored, "unexpected newly ignored paths")
+	assert.Equal(test, []string{"a.tmp"}, nowIncluded, "unexpected newly included paths")
+	nowIgnored, nowIncluded = DiffIgnored(old, old, paths)
+	assert.Nil(test, nowIgnored, "nothing should be newly ignored")
+	assert.Nil(test, nowIncluded, "nothing should be newly included")
+}
@@ -0,0 +1,104 @@
+//go:build go1.16
+// +build go1.16
+
+package ignore
+
+import (
+	"io/fs"
+	"path"
+)
+
+// filterFS is the fs.FS returned by GitIgnore.FilterFS
+type filterFS struct {
+	g    *GitIgnore
+	base fs.FS
+}
+
+// FilterFS returns a read-only fs.FS which serves the files of "base" but
+// hides the ignored ones: ReadDir omits ignored entries, and opening an
+// ignored path, or a path below an ignored directory, fails with
+// fs.ErrNotExist. The patterns apply at the root of "base", and
+// directory-only patterns [Rule 5] apply to its directories.
+func (g *GitIgnore) FilterFS(base fs.FS) fs.FS {
+	return &filterFS{g: g, base: base}
+}
+
+// hides returns true if the valid fs.FS path "name" is ignored, or lies
+// below an ignored directory
+func (f *filterFS) hides(name string) bool {
+	if name == "." {
+		return false
+	}
+	if dir := path.Dir(name); dir != "." && f.g.skipsDir(dir) {
+		return true
+	}
+	info, err := fs.Stat(f.base, name)
+	isDir := err == nil && info.IsDir()
+	return f.g.matchesRelPath(name, isDir) == Match
+}
+
+// check returns the error for an operation "op" on the path "name" which
+// is invalid or hidden, or nil if the path may be accessed
+func (f *filterFS) check(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if f.hides(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+// visible drops the ignored entries of the directory "dir" from "entries"
+func (f *filterFS) visible(dir string, entries []fs.DirEntry) []fs.DirEntry {
+	var kept []fs.DirEntry
+	for _, entry := range entries {
+		if f.g.matchesRelPath(path.Join(dir, entry.Name()), entry.IsDir()) != Match {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// Open implements fs.FS
+func (f *filterFS) Open(name string) (fs.File, error) {
+	if err := f.check("open", name); err != nil {
+		return nil, err
+	}
+	file, err := f.base.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := file.(fs.ReadDirFile); ok {
+		return &filterDir{ReadDirFile: dir, fsys: f, name: name}, nil
+	}
+	return file, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (f *filterFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if err := f.check("readdir", name); err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(f.base, name)
+	return f.visible(name, entries), err
+}
+
+// filterDir is an open directory of a filterFS
+type filterDir struct {
+	fs.ReadDirFile
+	fsys *filterFS
+	name string
+}
+
+// ReadDir implements fs.ReadDirFile, reading on past ignored entries so
+// that an empty result always comes with an error
+func (d *filterDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	for {
+		entries, err := d.ReadDirFile.ReadDir(n)
+		kept := d.fsys.visible(d.name, entries)
+		if len(kept) > 0 || err != nil || n <= 0 {
+			return kept, err
+		}
+	}
+}
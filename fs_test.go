@@ -0,0 +1,54 @@
+//go:build go1.16
+// +build go1.16
+
+// Implement tests for the fs.FS wrapper of the `ignore` library
+package ignore
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate "FilterFS()"
+func TestFilterFS(test *testing.T) {
+	base := fstest.MapFS{
+		"a.log":           {},
+		"main.go":         {},
+		"build/out.o":     {},
+		"src/b.log":       {},
+		"src/lib.go":      {},
+		"src/build/x.go":  {},
+		"docs/build":      {},
+		"vendor/keep.log": {},
+	}
+	object, err := CompileIgnoreLines("*.log", "/build/", "src/build/", "!vendor/*.log")
+	assert.Nil(test, err, "error from CompileIgnoreLines should be nil")
+	wrapped := object.FilterFS(base)
+
+	var visible []string
+	err = fs.WalkDir(wrapped, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		visible = append(visible, path)
+		return nil
+	})
+	assert.Nil(test, err, "error from WalkDir should be nil")
+	assert.Equal(test, []string{".", "docs", "docs/build", "main.go", "src", "src/lib.go", "vendor", "vendor/keep.log"}, visible)
+
+	for _, name := range []string{"a.log", "build", "build/out.o", "src/b.log", "src/build/x.go"} {
+		_, err := wrapped.Open(name)
+		assert.True(test, errors.Is(err, fs.ErrNotExist), name+" should not exist")
+		_, err = fs.Stat(wrapped, name)
+		assert.True(test, errors.Is(err, fs.ErrNotExist), name+" should not be found")
+	}
+
+	_, err = wrapped.Open("../a.log")
+	assert.True(test, errors.Is(err, fs.ErrInvalid), "paths outside of the file system are invalid")
+
+	assert.Nil(test, fstest.TestFS(wrapped, "main.go", "src/lib.go", "docs/build", "vendor/keep.log"), "the wrapper should be a valid fs.FS")
+}
@@ -48,14 +48,31 @@ The summarized version of the same has been copied here:
         iii. A slash followed by two consecutive asterisks then a slash matches
              zero or more directories. For example, "a/** /b" matches "a/b",
              "a/x/b", "a/x/y/b" and so on.
-        iv.  Other consecutive asterisks are considered invalid. */
+        iv.  Other consecutive asterisks are considered invalid.
+
+On Windows, where the path separator is a backslash, a backslash in a
+pattern separates path components like a slash, just as it does in the
+paths matched against it, so "build\temp" matches "build/temp". It still
+escapes a leading "#" or "!" and a trailing space there, and nothing else.
+Elsewhere a backslash always escapes the character after it. */
 package ignore
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"regexp/syntax"
+	"sort"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 const (
@@ -64,7 +81,9 @@ const (
 	Negation
 )
 
-// An IgnoreParser is an interface which exposes two methods:
+// An IgnoreParser is an interface which exposes three methods:
+//   IncludesPath() - Returns true if the path is re-included by a negated pattern
+//   IgnoresPath() - Returns true if the path is ignored by the patterns
 //   MatchesPath() - Returns true if the path is targeted by the patterns compiled in the GitIgnore structure
 type IgnoreParser interface {
 	IncludesPath(f string) bool
@@ -72,33 +91,148 @@ type IgnoreParser interface {
 	MatchesPath(f string) bool
 }
 
+// Options controls optional behaviour of the pattern compiler. The zero
+// value compiles patterns exactly like CompileIgnoreLines does.
+type Options struct {
+	// StrictGit makes compilation fail for patterns which use syntax that
+	// git itself does not understand (such as "{a,b}" brace expansion), so
+	// that ignore files stay portable between this library and git.
+	StrictGit bool
+
+	// RegexpFlags is a Go regexp flag group, such as "(?i)", which is
+	// prepended to every compiled pattern
+	RegexpFlags string
+
+	// CaseInsensitive matches the patterns regardless of case, like git
+	// does with core.ignorecase on case-insensitive file systems
+	CaseInsensitive bool
+
+	// AnchorEnd enables a non-git extension: a trailing "$" requires the
+	// pattern to match the whole path, so that it no longer targets the
+	// descendants of a matching directory. Escape it as "\$" to match a
+	// literal dollar sign.
+	AnchorEnd bool
+
+	// ExpandEnv expands "$VAR" and "${VAR}" references in every line
+	// before it is parsed. Variables are resolved with EnvMapping, or with
+	// os.Getenv if it is nil.
+	ExpandEnv  bool
+	EnvMapping func(string) string
+
+	// ConvertFrom selects the dialect the lines are written in, so that
+	// ignore files of other tools can be interpreted with their quirks.
+	// Paths a dialect always ignores, such as npm's node_modules, are
+	// matched before the rules but are not rules themselves: they are not
+	// listed by Rules and friends, have no rule index, and are applied once
+	// by a Merge of sets sharing the dialect.
+	ConvertFrom Dialect
+
+	// NormalizeUnicode, if not nil, is applied to every pattern and path
+	// before matching, so that names in different Unicode normalization
+	// forms compare equal. Pass norm.NFC.String of the package
+	// golang.org/x/text/unicode/norm to match a precomposed "café" with the
+	// decomposed form macOS often stores file names in. This library only
+	// depends on the standard library itself.
+	NormalizeUnicode func(string) string
+
+	// BangBang enables a non-git extension: a leading "!!" stands for a
+	// literal "!", the same as the standard "\!" escape, for users who
+	// expect it to work that way. Git reads "!!foo" as a negation of the
+	// literal "!foo", which is what happens without it.
+	BangBang bool
+
+	// Lazy defers compiling each pattern to its first use, so that sets
+	// which are loaded but seldom matched, such as those of thousands of
+	// repositories, stay cheap. The regexps are shared by all lazy sets
+	// through a cache keyed by their text, see SharedCacheStats.
+	Lazy bool
+}
+
+// expand resolves the environment variables referenced in a line
+func (opts Options) expand(line string) string {
+	if opts.EnvMapping == nil {
+		return os.ExpandEnv(line)
+	}
+	return os.Expand(line, opts.EnvMapping)
+}
+
+// validFlags matches the regexp flag groups accepted in Options.RegexpFlags
+var validFlags = regexp.MustCompile(`^(\(\?[imsU]*(-[imsU]+)?\))?$`)
+
 // GitIgnore is a struct which contains a slice of regexp.Regexp
 // patterns
 type GitIgnore struct {
-	basePath string
-	patterns []*regexp.Regexp // List of regexp patterns which this ignore file applies
-	negate   []bool           // List of booleans which determine if the pattern is negated
+	mu           sync.RWMutex // Guards the rules below against a concurrent ReloadFile or AddRule
+	basePath     string
+	fpath        string // The file the rules were compiled from, if any
+	opts         Options
+	patterns     []*lazyRegexp // List of regexp patterns which this ignore file applies
+	negate       []bool        // List of booleans which determine if the pattern is negated
+	dirOnly      []bool        // List of booleans which determine if the pattern only matches directories
+	childrenOnly []bool        // List of booleans which determine if the pattern only matches the direct children of a directory
+	sources      []string      // List of the original lines the patterns were compiled from
+	labels       []string      // List of the source labels attached to the patterns
+	lineNums     []int         // List of the 1-based input line numbers of the patterns, 0 if unknown
+	dirs         []string      // List of the directories below the base path the patterns apply in, "" for all of it
 }
 
-// This function pretty much attempts to mimic the parsing rules
-// listed above at the start of this file
-func getPatternFromLine(line string) (*regexp.Regexp, bool) {
+// trimLine strips the line ending and the trailing spaces from a line.
+// Leading spaces are significant in git, so "  #x" is a pattern matching a
+// file named "  #x" rather than a comment.
+func trimLine(line string) string {
 	// Trim OS-specific carriage returns.
 	line = strings.TrimRight(line, "\r")
 
+	// Trim string [Rule 3], keeping a last space escaped with a "\", which
+	// the regexp then reads as a literal space
+	trimmed := strings.TrimRight(line, " ")
+	backslashes := len(trimmed) - len(strings.TrimRight(trimmed, `\`))
+	if backslashes%2 == 1 && len(trimmed) < len(line) {
+		trimmed += " "
+	}
+	return trimmed
+}
+
+// descendantSuffix ends a pattern so that it matches the path itself or
+// anything below it. The group does not capture, which saves work on long
+// paths, and it matches exactly what the former "(|/.+)$" matched.
+const descendantSuffix = "(?:/.+)?$"
+
+// The regexps getPatternFromLine uses to rewrite a line, compiled once
+var (
+	commentLine        = regexp.MustCompile(`^#`)
+	escapedPrefix      = regexp.MustCompile(`^\\(\#|\!)`)
+	leadingSlash       = regexp.MustCompile(`^/`)
+	escapedOrMeta      = regexp.MustCompile(`\\.|[.+()|{}^$\]]`)
+	repeatedDoubleStar = regexp.MustCompile(`(^\^?|/)\*\*(/\*\*)+(/|$)`)
+	leadingDoubleStar  = regexp.MustCompile(`^\^?\*\*/`)
+	middleDoubleStar   = regexp.MustCompile(`/\*\*/`)
+	trailingDoubleStar = regexp.MustCompile(`/\*\*$`)
+	loneDoubleStar     = regexp.MustCompile(`^\^?\*\*$`)
+	escapedOrWildcard  = regexp.MustCompile(`\\.|\*|\?`)
+)
+
+// This function pretty much attempts to mimic the parsing rules
+// listed above at the start of this file
+func getPatternFromLine(line string, opts Options) (*lazyRegexp, bool, bool, bool, error) {
 	// Strip comments [Rule 2]
-	if regexp.MustCompile(`^#`).MatchString(line) {
-		return nil, false
+	if commentLine.MatchString(line) {
+		return nil, false, false, false, nil
 	}
 
-	// Trim string [Rule 3]
-	// TODO: Hanlde [Rule 3], when the " " is escaped with a \
-	line = strings.Trim(line, " ")
+	line = trimLine(line)
 
 	// Exit for no-ops and return nil which will prevent us from
 	// appending a pattern against this line
 	if line == "" {
-		return nil, false
+		return nil, false, false, false, nil
+	}
+	source := line
+
+	// Consecutive asterisks other than the three "**" forms, a leading
+	// "**/", a trailing "/**" and a middle "/**/", are invalid [Rule 9 iv]
+	if err := checkDoubleStar(line); err != nil {
+		return nil, false, false, false, err
 	}
 
 	// TODO: Handle [Rule 4] which negates the match for patterns leading with "!"
@@ -109,52 +243,498 @@ func getPatternFromLine(line string) (*regexp.Regexp, bool) {
 	}
 
 	// Handle [Rule 2, 4], when # or ! is escaped with a \
-	// Handle [Rule 4] once we tag negatePattern, strip the leading ! char
-	if regexp.MustCompile(`^(\#|\!)`).MatchString(line) {
+	// Once we tag negatePattern any further # or ! is a literal char. A
+	// leading "\\" is left to the pass quoting the literal parts below,
+	// which reads it as a literal backslash.
+	if escapedPrefix.MatchString(line) {
 		line = line[1:]
 	}
 
-	// Handle [Rule 8], strip leading / and enforce path checking if its present
-	if regexp.MustCompile(`^/`).MatchString(line) {
-		line = "^" + line[1:]
+	// Patterns written with the OS-specific path separator use it like the
+	// paths they are matched against, so on Windows a backslash separates
+	// path components instead of escaping. The escapes handled above, and
+	// an escaped trailing space [Rule 3], are kept.
+	if strings.HasSuffix(line, `\ `) {
+		line = filepath.ToSlash(line[:len(line)-2]) + `\ `
+	} else {
+		line = filepath.ToSlash(line)
+	}
+
+	// A trailing backslash escapes nothing, git never matches such a line
+	if trailing := len(line) - len(strings.TrimRight(line, `\`)); trailing%2 == 1 {
+		return nil, false, false, false, fmt.Errorf("pattern %q ends with an unescaped backslash", source)
+	}
+
+	// Handle [Rule 5], a trailing / restricts the pattern to directories
+	dirOnly := false
+	if len(line) > 1 && strings.HasSuffix(line, "/") {
+		// A trailing "/**/" is the same as "/**", everything inside
+		dirOnly = !strings.HasSuffix(line, "/**/")
+		line = line[:len(line)-1]
+	}
+
+	// A trailing "/*" targets the direct children of a directory only, so
+	// the pattern must not match their descendants. These are still ignored
+	// through their ignored parent, see ruleMatches.
+	childrenOnly := strings.HasSuffix(line, "/*")
+	noDescendants := childrenOnly
+
+	// Handle the optional trailing "$" marker, which forbids matching the
+	// descendants altogether
+	if opts.AnchorEnd && strings.HasSuffix(line, "$") && !strings.HasSuffix(line, `\$`) {
+		noDescendants, childrenOnly = true, false
+		line = line[:len(line)-1]
 	}
 
-	// If we encounter a foo/*.blah in a folder, prepend the ^ char
-	if regexp.MustCompile(`([^\/+])/.*\*\.`).MatchString(line) {
+	// Handle bracket expressions, which are set aside until the end so that
+	// the passes below leave their contents alone
+	line, classes := extractClasses(line)
+
+	// Quote the regexp metacharacters of the literal parts, so that names
+	// such as "a+b.txt" or "(cache)" match as written. A backslash escape
+	// stands for the character after it, and the glob tokens "*" and "?"
+	// are kept for the passes below.
+	line = escapedOrMeta.ReplaceAllStringFunc(line, func(m string) string {
+		return regexp.QuoteMeta(strings.TrimPrefix(m, `\`))
+	})
+
+	// Handle [Rule 8], strip leading / and enforce path checking if its present
+	if leadingSlash.MatchString(line) {
+		line = "^" + line[1:]
+	} else if strings.Contains(line, "/") {
+		// Handle [Rule 7], a slash anywhere else anchors the pattern too,
+		// as with FNM_PATHNAME. A trailing slash was stripped above, so
+		// "doc/" still matches at any depth.
 		line = "^" + line
+	} else if line != "**" {
+		// Handle [Rule 6], a pattern without a slash matches a whole path
+		// component at any depth, so "foo" does not target "barfoo"
+		line = "(^|/)" + line
 	}
 
-	// Handle escaping the "." char
-	line = regexp.MustCompile(`\.`).ReplaceAllString(line, `\.`)
+	// Handle "**" usage [Rule 9], the replacements must not contain a "*"
+	// Consecutive "**" components are the same as a single one
+	line = repeatedDoubleStar.ReplaceAllString(line, `${1}**${3}`)
+	// A leading "**/" matches in all directories
+	line = leadingDoubleStar.ReplaceAllString(line, `^(|.+/)`)
+	// A "/**/" matches zero or more directories
+	line = middleDoubleStar.ReplaceAllString(line, `/(|.+/)`)
+	// A trailing "/**" matches everything inside
+	line = trailingDoubleStar.ReplaceAllString(line, `/.+`)
+	// A lone "**" matches everything
+	line = loneDoubleStar.ReplaceAllString(line, `.+`)
 
-	// Handle "**" usage (and special case when it is followed by a /)
-	line = regexp.MustCompile(`\*\*(/|)`).ReplaceAllString(line, `(.+|)`)
+	// Handle the "*" and "?" wildcards, an escaped "\*" or "\?" is literal
+	line = escapedOrWildcard.ReplaceAllStringFunc(line, func(m string) string {
+		switch m {
+		case "*":
+			return `([^\/]*)`
+		case "?":
+			return `[^/]`
+		}
+		return m
+	})
 
-	// Handle escaping the "*" char
-	line = regexp.MustCompile(`\*`).ReplaceAllString(line, `([^\/]+)`)
+	// Put the bracket expressions back in place
+	parts := strings.Split(line, "\x00")
+	for i, class := range classes {
+		parts[i] += class
+	}
+	line = strings.Join(parts, "")
 
 	// Temporary regex
-	expr := line + "(|/.+)$"
-	pattern, _ := regexp.Compile(expr)
+	flags := opts.RegexpFlags
+	if opts.CaseInsensitive {
+		flags = "(?i)" + flags
+	}
+	expr := flags + line + descendantSuffix
+	if noDescendants {
+		expr = flags + line + "$"
+	}
+	if opts.Lazy {
+		// The regexp is only parsed here, so that a malformed line is still
+		// reported by the compile functions
+		if _, err := syntax.Parse(expr, syntax.Perl); err != nil {
+			return nil, false, false, false, fmt.Errorf("pattern %q cannot be compiled: %v", source, err)
+		}
+		return &lazyRegexp{expr: expr}, negatePattern, dirOnly, childrenOnly, nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, false, false, false, fmt.Errorf("pattern %q cannot be compiled: %v", source, err)
+	}
 
-	return pattern, negatePattern
+	return &lazyRegexp{re: pattern}, negatePattern, dirOnly, childrenOnly, nil
+}
+
+// extractClasses replaces the bracket expressions of "line" with a NUL
+// character each and returns them translated into Go regexp classes, in
+// order. A "[" which does not start a bracket expression is a literal.
+func extractClasses(line string) (string, []string) {
+	var b bytes.Buffer
+	var classes []string
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+		case line[i] == '[':
+			class, n := bracketClass(line[i:])
+			if n == 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			classes = append(classes, class)
+			b.WriteByte(0)
+			i += n - 1
+		default:
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String(), classes
+}
+
+// bracketClass translates the bracket expression at the start of "expr",
+// such as "[a-z]" or "[!abc]", into a Go regexp class which never matches
+// a "/". It returns the class and the length of the bracket expression,
+// or a zero length if "expr" does not start with a valid one.
+func bracketClass(expr string) (string, int) {
+	var src bytes.Buffer
+	src.WriteByte('[')
+	i := 1
+	if i < len(expr) && (expr[i] == '!' || expr[i] == '^') {
+		src.WriteByte('^')
+		i++
+	}
+	for first := i; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == ']' && i > first:
+			src.WriteByte(']')
+			class, ok := withoutSlash(src.String())
+			if !ok {
+				return "", 0
+			}
+			return class, i + 1
+		case c == '\\' && i+1 < len(expr):
+			// Only punctuation may be escaped within a Go regexp class
+			i++
+			if c := expr[i]; c < utf8.RuneSelf && !isAlnum(c) {
+				src.WriteByte('\\')
+			}
+			src.WriteByte(expr[i])
+		case strings.HasPrefix(expr[i:], "[:"):
+			// A character class such as "[:alpha:]"
+			end := strings.Index(expr[i+2:], ":]")
+			if end < 0 {
+				src.WriteString(`\[`)
+				continue
+			}
+			src.WriteString(expr[i : i+end+4])
+			i += end + 3
+		case c == '[' || c == ']':
+			src.WriteByte('\\')
+			src.WriteByte(c)
+		default:
+			src.WriteByte(c)
+		}
+	}
+	return "", 0
+}
+
+// withoutSlash parses the Go regexp class "class" and renders it again
+// without the "/", as wildcards never match it [Rule 7]
+func withoutSlash(class string) (string, bool) {
+	re, err := syntax.Parse(class, syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+	var ranges []rune
+	switch re.Op {
+	case syntax.OpCharClass:
+		ranges = re.Rune
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			ranges = append(ranges, r, r)
+		}
+	case syntax.OpAnyChar:
+		ranges = []rune{0, utf8.MaxRune}
+	case syntax.OpAnyCharNotNL:
+		ranges = []rune{0, '\n' - 1, '\n' + 1, utf8.MaxRune}
+	case syntax.OpNoMatch:
+	default:
+		return "", false
+	}
+
+	var b bytes.Buffer
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		if lo <= '/' && '/' <= hi {
+			if lo < '/' {
+				writeRange(&b, lo, '/'-1)
+			}
+			lo = '/' + 1
+		}
+		if lo <= hi {
+			writeRange(&b, lo, hi)
+		}
+	}
+	if b.Len() == 0 {
+		// Nothing but "/" would match, so nothing matches at all
+		return `[^\x00-\x{10FFFF}]`, true
+	}
+	return "[" + b.String() + "]", true
+}
+
+// writeRange writes the range of runes from "lo" to "hi" in Go regexp
+// class syntax
+func writeRange(b *bytes.Buffer, lo, hi rune) {
+	fmt.Fprintf(b, `\x{%x}`, lo)
+	if hi != lo {
+		fmt.Fprintf(b, `-\x{%x}`, hi)
+	}
+}
+
+// isAlnum returns true for the ASCII letters and digits
+func isAlnum(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// checkStrictGit returns an error if the line uses syntax which is not part
+// of the gitignore format. Backslash escaped characters are always allowed.
+func checkStrictGit(line string) error {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '{', '}':
+			return fmt.Errorf("pattern %q uses brace expansion, which git does not support", line)
+		}
+	}
+	return nil
+}
+
+// checkDoubleStar returns an error if the line contains consecutive
+// asterisks other than a "**" forming a whole path component [Rule 9]
+func checkDoubleStar(line string) error {
+	line = strings.TrimPrefix(trimLine(line), "!")
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] != '*' {
+			continue
+		}
+		j := i
+		for j < len(line) && line[j] == '*' {
+			j++
+		}
+		if j-i > 1 && (j-i > 2 || (i > 0 && line[i-1] != '/') || (j < len(line) && line[j] != '/')) {
+			return fmt.Errorf("pattern %q has an invalid %q sequence", line, line[i:j])
+		}
+		i = j - 1
+	}
+	return nil
 }
 
 // CompileIgnoreLines accepts a variadic set of strings, and returns a GitIgnore object which
 // converts and appends the lines in the input to regexp.Regexp patterns
 // held within the GitIgnore objects "patterns" field
 func CompileIgnoreLines(lines ...string) (*GitIgnore, error) {
-	g := new(GitIgnore)
-	for _, line := range lines {
-		pattern, negatePattern := getPatternFromLine(line)
-		if pattern != nil {
-			g.patterns = append(g.patterns, pattern)
-			g.negate = append(g.negate, negatePattern)
+	return CompileIgnoreLinesWithOptions(Options{}, lines...)
+}
+
+// CompileIgnoreLinesWithOptions works like CompileIgnoreLines, but compiles
+// the lines according to the given options. Malformed lines are an error,
+// which names every one of them with its line number and text.
+func CompileIgnoreLinesWithOptions(opts Options, lines ...string) (*GitIgnore, error) {
+	if !validFlags.MatchString(opts.RegexpFlags) {
+		return nil, fmt.Errorf("invalid regexp flags %q", opts.RegexpFlags)
+	}
+
+	g, errs := compileLines(opts, lines)
+	if errs != nil {
+		return nil, lineErrors(errs)
+	}
+	return g, nil
+}
+
+// CompileIgnoreLinesCaseInsensitive works like CompileIgnoreLines, but the
+// patterns match regardless of case, see Options.CaseInsensitive
+func CompileIgnoreLinesCaseInsensitive(lines ...string) (*GitIgnore, error) {
+	return CompileIgnoreLinesWithOptions(Options{CaseInsensitive: true}, lines...)
+}
+
+// CompileIgnoreLinesScoped works like CompileIgnoreLines, but the patterns
+// apply within the subdirectory "scope" only, as if they were read from a
+// .gitignore file there: they are anchored to "scope" and match nothing
+// outside of it. The scope is relative to where the paths are matched.
+func CompileIgnoreLinesScoped(scope string, lines ...string) (*GitIgnore, error) {
+	dir := path.Clean(filepath.ToSlash(scope))
+	if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return nil, fmt.Errorf("scope %s is not a subdirectory", scope)
+	}
+	g, err := CompileIgnoreLines(lines...)
+	if err != nil {
+		return nil, err
+	}
+	if dir != "." {
+		for idx := range g.dirs {
+			g.dirs[idx] = dir
 		}
 	}
 	return g, nil
 }
 
+// CompileIgnoreLinesWithError works like CompileIgnoreLines, but compiles
+// every line it can. The GitIgnore holds the patterns of the good lines,
+// and a LineError is returned for each of the malformed ones, so that a
+// linter can report all of them at once.
+func CompileIgnoreLinesWithError(lines ...string) (*GitIgnore, []LineError) {
+	return compileLines(Options{}, lines)
+}
+
+// compileLines compiles "lines" with the options "opts", collecting the
+// errors of the malformed lines
+func compileLines(opts Options, lines []string) (*GitIgnore, []LineError) {
+	g := &GitIgnore{opts: opts}
+	var errs []LineError
+	for idx, line := range lines {
+		if _, err := g.addLine(line, "", idx+1); err != nil {
+			errs = append(errs, LineError{LineNumber: idx + 1, Text: line, Err: err})
+		}
+	}
+	return g, errs
+}
+
+// LineError describes a line of an ignore file which cannot be compiled
+type LineError struct {
+	LineNumber int    // 1-based number of the line
+	Text       string // The line as given
+	Err        error  // Why the line cannot be compiled
+}
+
+func (e LineError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.LineNumber, e.Err)
+}
+
+// lineErrors is the error for the malformed lines of an ignore file. Each
+// line is reported, so that all of them can be fixed at once.
+type lineErrors []LineError
+
+func (e lineErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// addLine compiles a single line with the options of g and appends it,
+// tagged with the given label and input line number. It reports whether a
+// rule was added, which is not the case for blank lines and comments.
+func (g *GitIgnore) addLine(line, label string, lineNum int) (bool, error) {
+	if g.opts.ExpandEnv {
+		line = g.opts.expand(line)
+	}
+	if g.opts.NormalizeUnicode != nil {
+		line = g.opts.NormalizeUnicode(line)
+	}
+	line = g.opts.ConvertFrom.convert(line)
+	if g.opts.BangBang && strings.HasPrefix(line, "!!") {
+		line = `\!` + line[2:]
+	}
+	pattern, negatePattern, dirOnly, childrenOnly, err := getPatternFromLine(line, g.opts)
+	if err != nil {
+		return false, err
+	}
+	if pattern == nil {
+		return false, nil
+	}
+	if g.opts.StrictGit {
+		if err := checkStrictGit(line); err != nil {
+			return false, err
+		}
+	}
+	g.patterns = append(g.patterns, pattern)
+	g.negate = append(g.negate, negatePattern)
+	g.dirOnly = append(g.dirOnly, dirOnly)
+	g.childrenOnly = append(g.childrenOnly, childrenOnly)
+	g.sources = append(g.sources, trimLine(line))
+	g.labels = append(g.labels, label)
+	g.lineNums = append(g.lineNums, lineNum)
+	g.dirs = append(g.dirs, "")
+	return true, nil
+}
+
+// scanLines is a bufio.SplitFunc which, unlike bufio.ScanLines, accepts
+// "\n", "\r\n" as well as a lone "\r" as the line separator
+func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		switch {
+		case data[i] == '\n':
+			return i + 1, data[:i], nil
+		case i+1 < len(data) && data[i+1] == '\n':
+			return i + 2, data[:i], nil
+		case i+1 < len(data) || atEOF:
+			return i + 1, data[:i], nil
+		}
+		// Request more data to tell a "\r\n" from a lone "\r"
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// splitLines splits the contents of an ignore file into lines
+func splitLines(buffer []byte) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(buffer))
+	scanner.Buffer(nil, len(buffer)+1)
+	scanner.Split(scanLines)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// CompileIgnoreScanner compiles the lines produced by an already configured
+// scanner, one at a time, so that callers control the buffer size and the
+// split function. Scanning errors, such as bufio.ErrTooLong, are returned.
+func CompileIgnoreScanner(s *bufio.Scanner) (*GitIgnore, error) {
+	g, _ := CompileIgnoreLines()
+	var errs lineErrors
+	for idx := 0; s.Scan(); idx++ {
+		if _, err := g.addLine(s.Text(), "", idx+1); err != nil {
+			errs = append(errs, LineError{LineNumber: idx + 1, Text: s.Text(), Err: err})
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return g, nil
+}
+
+// CompileIgnoreReader compiles the lines read from "r", such as an HTTP
+// response body or an embedded asset. Lines may be arbitrarily long, and
+// any read error is returned. Like with CompileIgnoreLines, paths are
+// matched as given, without a base path.
+func CompileIgnoreReader(r io.Reader) (*GitIgnore, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, int(^uint(0)>>1))
+	scanner.Split(scanLines)
+	return CompileIgnoreScanner(scanner)
+}
+
 // CompileIgnoreFile accepts a ignore file as the input, parses the lines out of the file
 // and invokes the CompileIgnoreLines method. Note that the location
 // of a .gitignore file is taken into account for relative filename matching.
@@ -163,39 +743,839 @@ func CompileIgnoreFile(fpath string) (*GitIgnore, error) {
 	if err != nil {
 		return nil, err
 	}
-	s := strings.Split(string(buffer), "\n")
+	s, err := splitLines(buffer)
+	if err != nil {
+		return nil, err
+	}
 	res, err := CompileIgnoreLines(s...)
 	if err != nil {
 		return nil, err
 	}
-	res.basePath = filepath.Dir(fpath)
+	res.basePath = filepath.Clean(filepath.Dir(fpath))
+	res.fpath = fpath
 	return res, nil
 }
 
+// ReloadFile re-reads the file g was compiled from with CompileIgnoreFile
+// and replaces its rules, keeping the original options. All methods of g
+// may run concurrently with a reload, and each call sees either the old or
+// the new rules. On error the current rules are left in place.
+func (g *GitIgnore) ReloadFile() error {
+	if g.fpath == "" {
+		return errors.New("not compiled from a file")
+	}
+	buffer, err := ioutil.ReadFile(g.fpath)
+	if err != nil {
+		return err
+	}
+	s, err := splitLines(buffer)
+	if err != nil {
+		return err
+	}
+	res, err := CompileIgnoreLinesWithOptions(g.opts, s...)
+	if err != nil {
+		return err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.replaceRules(res)
+	return nil
+}
+
+// replaceRules replaces all the patterns of g with the ones of "res". The
+// caller holds g.mu for writing.
+func (g *GitIgnore) replaceRules(res *GitIgnore) {
+	g.patterns, g.negate, g.dirOnly, g.childrenOnly = res.patterns, res.negate, res.dirOnly, res.childrenOnly
+	g.sources, g.labels, g.lineNums, g.dirs = res.sources, res.labels, res.lineNums, res.dirs
+}
+
+// CompileRepoExclude compiles the repository-local exclude file found at
+// ".git/info/exclude" below "repoRoot". Its patterns apply to the whole
+// work tree, so paths are matched relative to "repoRoot" rather than to
+// the location of the file. A missing exclude file yields an empty
+// GitIgnore.
+func CompileRepoExclude(repoRoot string) (*GitIgnore, error) {
+	res, err := CompileIgnoreFile(filepath.Join(repoRoot, ".git", "info", "exclude"))
+	if os.IsNotExist(err) {
+		res, err = CompileIgnoreLines()
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.basePath = filepath.Clean(repoRoot)
+	return res, nil
+}
+
+// CompileIgnoreDir compiles every file in "dir" whose name matches the glob
+// "pattern", such as "*.ignore", in sorted order. The rules of later files
+// take precedence over those of earlier ones, as with Merge. Paths are
+// matched relative to "dir".
+func CompileIgnoreDir(dir, pattern string) (*GitIgnore, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	res, _ := CompileIgnoreLines()
+	for _, fpath := range matches {
+		if info, err := os.Stat(fpath); err != nil || info.IsDir() {
+			continue
+		}
+		other, err := CompileIgnoreFile(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fpath, err)
+		}
+		res.appendRules(other, "")
+	}
+	res.basePath = filepath.Clean(dir)
+	return res, nil
+}
+
+// CompileIgnoreFiles compiles the ignore files "fpaths" into one GitIgnore,
+// in order, so that the patterns of a later file take precedence over the
+// ones of earlier files, as in a global excludes file followed by the
+// .gitignore of a repository. Paths are matched relative to the base path
+// of the last file, or of the outermost earlier file holding it, which is
+// the root of the repository when its .gitignore files are listed from the
+// top down. The patterns of a file below it only apply to that subtree, see
+// Merge, and the ones of a file elsewhere, such as the global excludes
+// file, apply from the base path. An error, including that
+// of a missing file, is wrapped with the path of the file.
+func CompileIgnoreFiles(fpaths ...string) (*GitIgnore, error) {
+	sets := make([]*GitIgnore, len(fpaths))
+	for i, fpath := range fpaths {
+		set, err := CompileIgnoreFile(fpath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fpath, err)
+		}
+		sets[i] = set
+	}
+
+	res, _ := CompileIgnoreLines()
+	if len(sets) > 0 {
+		res.basePath = sets[len(sets)-1].basePath
+	}
+	// The bases holding the last one are nested, so this ends at the
+	// outermost of them
+	for _, set := range sets {
+		if subdir(set.basePath, res.basePath) != "" {
+			res.basePath = set.basePath
+		}
+	}
+	for _, set := range sets {
+		res.appendRules(set, subdir(res.basePath, set.basePath))
+	}
+	return res, nil
+}
+
+// CompileLayered compiles git's stack of ignore files into one GitIgnore:
+// the global excludes file "global" (core.excludesFile) has the lowest
+// precedence, the repository exclude file "exclude" (.git/info/exclude)
+// comes next, and the .gitignore files "repoFiles" of the work tree, in
+// order, win over both. Within that order the last pattern targeting a
+// path decides, so that a negation in a higher layer re-includes a path a
+// lower one ignores. The repository files are combined as by
+// CompileIgnoreFiles, and the patterns of "global" and "exclude" apply to
+// the whole work tree, wherever the files are. The work tree is the base
+// path of the repository files, or the directory holding the ".git"
+// directory of "exclude" if there are none. Either of "global" and
+// "exclude" may be empty or name a missing file, which git ignores too.
+func CompileLayered(global, exclude string, repoFiles ...string) (*GitIgnore, error) {
+	repo, err := CompileIgnoreFiles(repoFiles...)
+	if err != nil {
+		return nil, err
+	}
+	res, _ := CompileIgnoreLines()
+	res.basePath = repo.basePath
+	if len(repoFiles) == 0 && exclude != "" {
+		res.basePath = filepath.Dir(filepath.Dir(filepath.Dir(exclude)))
+	}
+
+	for _, fpath := range []string{global, exclude} {
+		if fpath == "" {
+			continue
+		}
+		layer, err := CompileIgnoreFile(fpath)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fpath, err)
+		}
+		res.appendRules(layer, "")
+	}
+	res.appendRules(repo, "")
+	return res, nil
+}
+
+// CompileIgnoreTree walks the file tree rooted at "root" and compiles the
+// .gitignore file of every directory into a single GitIgnore matching paths
+// relative to "root". Like in git, the patterns of each file only apply
+// below its own directory, those of deeper files take precedence over the
+// ones of shallower files, and the files in ignored directories, or in
+// ".git", are not read. Errors name the offending file.
+func CompileIgnoreTree(root string) (*GitIgnore, error) {
+	res, _ := CompileIgnoreLines()
+	res.basePath = filepath.Clean(root)
+	err := filepath.Walk(root, func(fpath string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if fpath != root && (info.Name() == ".git" || res.matchesRelPath(relativePath(res.basePath, fpath), true) == Match) {
+			return filepath.SkipDir
+		}
+		other, err := CompileIgnoreFile(filepath.Join(fpath, ".gitignore"))
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", filepath.Join(fpath, ".gitignore"), err)
+		}
+		res.appendRules(other, subdir(res.basePath, other.basePath))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Merge combines several GitIgnore objects into one, keeping the order of
+// their patterns. The patterns are evaluated as a single sequence, so a
+// negation in a later set re-includes a path ignored by an earlier one,
+// just as later ignore files take precedence in git. The merged object
+// matches paths relative to the base path of the first set. The patterns
+// of a set whose base path lies below that one keep applying relative to
+// their own base path, and only to the paths below it, like the patterns
+// of a nested .gitignore file.
+func Merge(sets ...*GitIgnore) *GitIgnore {
+	g := new(GitIgnore)
+	for idx, other := range sets {
+		if idx == 0 {
+			g.basePath = other.basePath
+			g.opts = other.opts
+		}
+		g.appendRules(other, subdir(g.basePath, other.basePath))
+	}
+	return g
+}
+
+// subdir returns the slash separated path of the directory "dir" relative
+// to "base", or "" if it is "base" itself or does not lie below it
+func subdir(base, dir string) string {
+	rel, err := filepath.Rel(base, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// appendRules appends all the patterns of "other" after the ones of g,
+// moving them down to the directory "dir" below the base path unless it is
+// empty
+func (g *GitIgnore) appendRules(other *GitIgnore, dir string) {
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+	for idx := range other.patterns {
+		g.appendRule(other, idx)
+		if dir != "" {
+			last := len(g.dirs) - 1
+			g.dirs[last] = path.Join(dir, g.dirs[last])
+		}
+	}
+}
+
+// appendRule appends the pattern at index "idx" of "other" to g
+func (g *GitIgnore) appendRule(other *GitIgnore, idx int) {
+	g.patterns = append(g.patterns, other.patterns[idx])
+	g.negate = append(g.negate, other.negate[idx])
+	g.dirOnly = append(g.dirOnly, other.dirOnly[idx])
+	g.childrenOnly = append(g.childrenOnly, other.childrenOnly[idx])
+	g.sources = append(g.sources, other.sources[idx])
+	g.labels = append(g.labels, other.labels[idx])
+	g.lineNums = append(g.lineNums, other.lineNums[idx])
+	g.dirs = append(g.dirs, other.dirs[idx])
+}
+
 // MatchesPath is an interface function for the IgnoreParser interface.
 // It returns true if the given GitIgnore structure would target a given
-// path string "f"
-func (g GitIgnore) MatchesPath(f string) int {
+// path string "f", either to ignore it or to re-include it
+func (g *GitIgnore) MatchesPath(f string) bool {
+	return g.matchesPath(f) != NonMatch
+}
+
+// MatchesPathIsDir is like MatchesPath, but tells explicitly whether the
+// path string "f" names a directory instead of relying on a trailing slash.
+// Directory-only patterns [Rule 5] match it only if "isDir" is true, or
+// through one of its parent directories.
+func (g *GitIgnore) MatchesPathIsDir(f string, isDir bool) bool {
+	f, trailingSlash := g.relPath(f)
+	return g.matchesRelPath(f, isDir || trailingSlash) != NonMatch
+}
+
+// IncludesPath is an interface function for the IgnoreParser interface.
+// It returns true if a negation re-includes the path string "f", which it
+// cannot do below an ignored directory [Rule 4]
+func (g *GitIgnore) IncludesPath(f string) bool {
+	return g.hierarchicalResult(f) == Negation
+}
+
+// MatchesPathHow returns how the patterns target the path string "f": one
+// of Match, NonMatch or Negation. Like "git check-ignore -v" it also returns
+// the source text and the 1-based line number of the pattern deciding the
+// result, which is the last one targeting the path. These are empty for a
+// NonMatch, and the line number is 0 for rules added with AddRule.
+func (g *GitIgnore) MatchesPathHow(f string) (result int, source string, line int) {
+	f, isDir := g.relPath(f)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	result, idx := g.evaluateLocked(f, isDir, nil)
+	if idx < 0 {
+		return result, "", 0
+	}
+	return result, g.sources[idx], g.lineNums[idx]
+}
+
+// matchesPath returns the result of matching the path "f" relative to the
+// base path
+func (g *GitIgnore) matchesPath(f string) int {
+	f, isDir := g.relPath(f)
+	return g.matchesRelPath(f, isDir)
+}
+
+// relPath converts the path "f" into a slash separated path relative to the
+// location of the .gitignore file, and reports whether it names a directory
+func (g *GitIgnore) relPath(f string) (string, bool) {
 	// Replace OS-specific path separator.
 	f = filepath.ToSlash(f)
 
-	// Make file path relative to location of .gitignore file if possible
-	relFp, err := filepath.Rel(g.basePath, f)
+	// A trailing slash marks the path as a directory
+	return relativePath(g.basePath, f), strings.HasSuffix(f, "/")
+}
+
+// relativePath makes the path "f" relative to "base" if possible
+func relativePath(base, f string) string {
+	relFp, err := filepath.Rel(base, f)
 	if err == nil {
 		f = relFp
 	}
+	return strings.TrimSuffix(filepath.ToSlash(f), "/")
+}
+
+// MatchStats collects diagnostics about the work done by a match
+type MatchStats struct {
+	Evaluations int // Number of regexp evaluations performed
+}
+
+// evaluated records a single regexp evaluation
+func (s *MatchStats) evaluated() {
+	if s != nil {
+		s.Evaluations++
+	}
+}
+
+// MatchesPathStats returns the result of MatchesPathHow and additionally
+// records in "stats" how much work the match took. The counters are reset first.
+func (g *GitIgnore) MatchesPathStats(f string, stats *MatchStats) int {
+	*stats = MatchStats{}
+	f, isDir := g.relPath(f)
+	result, _ := g.evaluate(f, isDir, stats)
+	return result
+}
+
+// MatchesPathReordered is like MatchesPathHow, but evaluates the rules in the
+// given order of their indices instead of the order they were compiled in,
+// to explore the effect of moving a rule. The order must be a permutation
+// of all rule indices.
+func (g *GitIgnore) MatchesPathReordered(f string, order []int) (int, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if len(order) != len(g.patterns) {
+		return NonMatch, fmt.Errorf("order has %d indices, want %d", len(order), len(g.patterns))
+	}
+	seen := make([]bool, len(order))
+	reordered := &GitIgnore{basePath: g.basePath, opts: g.opts}
+	for _, idx := range order {
+		if idx < 0 || idx >= len(seen) || seen[idx] {
+			return NonMatch, fmt.Errorf("order is not a permutation, bad index %d", idx)
+		}
+		seen[idx] = true
+		reordered.appendRule(g, idx)
+	}
+	return reordered.matchesPath(f), nil
+}
+
+// MatchesPathTrimPrefix strips "prefix" from the path "f" and returns true
+// if the remainder is ignored, like IgnoresPath would say. This
+// allows matching absolute paths against a known root without setting up
+// a base path. The prefix must end on a path component boundary, so
+// "/var/repo" is not stripped from "/var/repository/a.log".
+func (g *GitIgnore) MatchesPathTrimPrefix(prefix, f string) bool {
+	prefix = filepath.ToSlash(prefix)
+	f = filepath.ToSlash(f)
+	isDir := strings.HasSuffix(f, "/")
+	if prefix != "" {
+		// The root "/" already ends on a boundary
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+		if !strings.HasPrefix(f, prefix) {
+			return false
+		}
+		f = f[len(prefix):]
+	}
+	return g.hierarchicalRelResult(strings.TrimSuffix(f, "/"), isDir) == Match
+}
+
+// matchesRelPath evaluates the patterns against a slash separated path
+// which is already relative to the location of the .gitignore file.
+// "isDir" tells whether the path names a directory.
+func (g *GitIgnore) matchesRelPath(f string, isDir bool) int {
+	result, _ := g.evaluate(f, isDir, nil)
+	return result
+}
+
+// evaluate works like matchesRelPath and additionally returns the index of
+// the deciding pattern, which is the last one targeting the path, or -1
+// for a NonMatch. The work done is recorded in "stats" unless it is nil.
+func (g *GitIgnore) evaluate(f string, isDir bool, stats *MatchStats) (int, int) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.evaluateLocked(f, isDir, stats)
+}
+
+// evaluateLocked is evaluate for callers which already hold g.mu
+func (g *GitIgnore) evaluateLocked(f string, isDir bool, stats *MatchStats) (int, int) {
+	// The directory holding the .gitignore file is never ignored itself
+	if f == "." || f == "" {
+		return NonMatch, -1
+	}
+
+	// Neither is anything outside of it
+	if f == ".." || strings.HasPrefix(f, "../") {
+		return NonMatch, -1
+	}
+
+	if g.opts.NormalizeUnicode != nil {
+		f = g.opts.NormalizeUnicode(f)
+	}
+
+	// Dialect defaults come first, so a negation may still re-include them
+	matchesPath, decidedBy := g.opts.ConvertFrom.evaluateDefaults(f, isDir, stats), -1
+	directly := true
+	for idx := range g.patterns {
+		matched, direct := g.ruleMatchesHow(idx, f, isDir, stats)
+		if !matched {
+			continue
+		}
+		// If this is a regular target (not negated with a gitignore exclude "!" etc)
+		if !g.negate[idx] {
+			matchesPath, decidedBy, directly = Match, idx, direct
+			// Negated pattern, and matchesPath is already set. A negation
+			// which only targets a parent directory cannot re-include a path
+			// an earlier pattern targets by itself, as in "*" and "!*/"
+		} else if matchesPath != NonMatch && (direct || !directly) {
+			matchesPath, decidedBy, directly = Negation, idx, direct
+		}
+	}
+	return matchesPath, decidedBy
+}
+
+// ruleMatches returns true if the pattern at index "idx" targets the
+// relative path "f". Directory-only patterns match a file solely through
+// one of its parent directories, and so do the descendants of the
+// directories a trailing "/*" targets.
+func (g *GitIgnore) ruleMatches(idx int, f string, isDir bool, stats *MatchStats) bool {
+	matched, _ := g.ruleMatchesHow(idx, f, isDir, stats)
+	return matched
+}
+
+// ruleMatchesHow works like ruleMatches and additionally reports whether
+// the pattern targets "f" itself rather than one of its parent directories
+func (g *GitIgnore) ruleMatchesHow(idx int, f string, isDir bool, stats *MatchStats) (bool, bool) {
+	// Patterns of a nested set only apply below its directory
+	if dir := g.dirs[idx]; dir != "" {
+		if !strings.HasPrefix(f, dir+"/") {
+			return false, false
+		}
+		f = f[len(dir)+1:]
+	}
+	pattern := g.patterns[idx].get()
+	stats.evaluated()
+	if pattern.MatchString(f) {
+		if !g.dirOnly[idx] || isDir {
+			return true, true
+		}
+	} else if !g.childrenOnly[idx] {
+		// Other patterns already match the descendants of what they match
+		return false, false
+	}
+	for dir := path.Dir(f); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		stats.evaluated()
+		if pattern.MatchString(dir) {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// RuleMatch is the location of a match of a single rule, see
+// GitIgnore.AllMatchIndices
+type RuleMatch struct {
+	Index int   // Index of the rule in order of precedence
+	Loc   []int // Byte offsets of the match in the relative path, as with regexp.FindStringIndex
+}
+
+// AllMatchIndices returns the match location of every rule whose regexp
+// matches the path "f", relative to the base path, in order of the rules.
+// Negations are included, and nothing else decides whether the path is
+// actually ignored. With NormalizeUnicode the offsets are those of the
+// normalized path.
+func (g *GitIgnore) AllMatchIndices(f string) []RuleMatch {
+	f, _ = g.relPath(f)
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.opts.NormalizeUnicode != nil {
+		f = g.opts.NormalizeUnicode(f)
+	}
 
-	matchesPath := NonMatch
+	var matches []RuleMatch
 	for idx, pattern := range g.patterns {
-		if pattern.MatchString(f) {
-			// If this is a regular target (not negated with a gitignore exclude "!" etc)
-			if !g.negate[idx] {
-				matchesPath = Match
-				// Negated pattern, and matchesPath is already set
-			} else if matchesPath == Match {
-				matchesPath = Negation
+		rel, offset := f, 0
+		if dir := g.dirs[idx]; dir != "" {
+			if !strings.HasPrefix(f, dir+"/") {
+				continue
+			}
+			rel, offset = f[len(dir)+1:], len(dir)+1
+		}
+		if loc := pattern.get().FindStringIndex(rel); loc != nil {
+			// A pattern without a slash starts at a component boundary
+			if loc[0] < loc[1] && rel[loc[0]] == '/' {
+				loc[0]++
+			}
+			matches = append(matches, RuleMatch{Index: idx, Loc: []int{loc[0] + offset, loc[1] + offset}})
+		}
+	}
+	return matches
+}
+
+// literalLength counts the characters of a pattern which are not wildcards
+func literalLength(pattern string) int {
+	n := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?', '[', ']':
+		case '\\':
+			i++
+			n++
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// MostSpecificMatch returns the index of the pattern targeting the path "f"
+// which has the most literal (non-wildcard) characters, as a proxy for the
+// rule which "really" applies to it. Of equally specific patterns the last
+// one wins. "ok" is false if no pattern targets the path at all.
+func (g *GitIgnore) MostSpecificMatch(f string) (index int, ok bool) {
+	f, isDir := g.relPath(f)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	best := -1
+	for idx := range g.patterns {
+		if !g.ruleMatches(idx, f, isDir, nil) {
+			continue
+		}
+		if n := literalLength(strings.TrimPrefix(g.sources[idx], "!")); n >= best {
+			index, best, ok = idx, n, true
+		}
+	}
+	return index, ok
+}
+
+// HasMatchesUnder is a conservative pre-check which returns false only if
+// no pattern can ignore the path "prefix" or anything below it, so that a
+// walker may skip the subtree. Patterns which are not anchored by a slash,
+// or which contain "**", are always assumed to match, and so are all
+// patterns when RegexpFlags, CaseInsensitive or NormalizeUnicode may change
+// how they match.
+func (g *GitIgnore) HasMatchesUnder(prefix string) bool {
+	if g.opts.RegexpFlags != "" || g.opts.CaseInsensitive || g.opts.NormalizeUnicode != nil || g.opts.ConvertFrom.hasDefaults() {
+		return true
+	}
+	prefix, _ = g.relPath(prefix)
+	var dirs []string
+	if prefix != "" && prefix != "." {
+		dirs = strings.Split(prefix, "/")
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for idx, source := range g.sources {
+		// Negations never cause a path to be ignored
+		if g.negate[idx] {
+			continue
+		}
+		// The patterns of a nested set are anchored to its directory
+		var base []string
+		if dir := g.dirs[idx]; dir != "" {
+			base = strings.Split(dir, "/")
+		}
+		line := strings.TrimSuffix(source, "/")
+		// The "$" marker is no part of the last segment
+		if g.opts.AnchorEnd && strings.HasSuffix(line, "$") && !strings.HasSuffix(line, `\$`) {
+			line = line[:len(line)-1]
+		}
+		if !strings.Contains(line, "/") || strings.Contains(line, "**") {
+			if anchoredPrefixMatches(base, dirs) {
+				return true
 			}
+			continue
+		}
+		if anchoredPrefixMatches(append(base, strings.Split(strings.TrimPrefix(line, "/"), "/")...), dirs) {
+			return true
+		}
+	}
+	return false
+}
+
+// anchoredPrefixMatches returns true if the leading segments of an anchored
+// pattern are compatible with the segments of a directory prefix. Segments
+// with a bracket expression are assumed to match, as path.Match reads them
+// differently than the compiler: "[!a-z]" is a negated class in git only.
+func anchoredPrefixMatches(segments, dirs []string) bool {
+	for i := 0; i < len(segments) && i < len(dirs); i++ {
+		if strings.Contains(segments[i], "[") {
+			continue
+		}
+		if ok, err := path.Match(segments[i], dirs[i]); !ok && err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// IgnoresPath is an interface function for the IgnoreParser interface.
+// It returns true if the path "f" is ignored, that is targeted by a pattern
+// and not re-included by a later negation, or if one of the directories
+// holding it is ignored, as negations are void below those [Rule 4].
+func (g *GitIgnore) IgnoresPath(f string) bool {
+	return g.hierarchicalResult(f) == Match
+}
+
+// hierarchicalResult works like matchesPath, but returns Match for a path
+// below an ignored directory whatever its own patterns say
+func (g *GitIgnore) hierarchicalResult(f string) int {
+	return g.hierarchicalRelResult(g.relPath(f))
+}
+
+// hierarchicalRelResult works like hierarchicalResult for a slash separated
+// path which is already relative to the base path, see matchesRelPath
+func (g *GitIgnore) hierarchicalRelResult(f string, isDir bool) int {
+	if dir := path.Dir(f); dir != "." && dir != "/" && g.skipsDir(dir) {
+		return Match
+	}
+	return g.matchesRelPath(f, isDir)
+}
+
+// IgnoredRelativeTo returns the ignored paths among "paths", rewritten
+// relative to "cwd" for display. Paths which cannot be made relative to
+// "cwd" are returned unchanged.
+func (g *GitIgnore) IgnoredRelativeTo(cwd string, paths []string) []string {
+	var ignored []string
+	for _, f := range paths {
+		if !g.IgnoresPath(f) {
+			continue
+		}
+		if rel, err := filepath.Rel(cwd, f); err == nil {
+			f = rel
 		}
+		ignored = append(ignored, f)
+	}
+	return ignored
+}
+
+// IgnoreKind describes why a path is ignored, see GitIgnore.IgnoreKind
+type IgnoreKind int
+
+const (
+	NotIgnored         IgnoreKind = iota // The path is not ignored
+	IgnoredAsFile                        // A pattern matching files and directories ignores the path
+	IgnoredAsDirectory                   // A directory-only pattern ignores the path
+	IgnoredViaAncestor                   // A directory holding the path is ignored
+)
+
+// IgnoreKind reports why the path "f" is ignored. A path inside an ignored
+// directory is IgnoredViaAncestor, as git never looks into such a directory
+// and no negation can re-include it. Otherwise the kind depends on whether
+// the pattern deciding the path is restricted to directories.
+func (g *GitIgnore) IgnoreKind(f string, isDir bool) IgnoreKind {
+	f, trailingSlash := g.relPath(f)
+	isDir = isDir || trailingSlash
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	segments := strings.Split(f, "/")
+	for i := 1; i < len(segments); i++ {
+		if result, _ := g.evaluateLocked(strings.Join(segments[:i], "/"), true, nil); result == Match {
+			return IgnoredViaAncestor
+		}
+	}
+
+	result, idx := g.evaluateLocked(f, isDir, nil)
+	switch {
+	case result != Match:
+		return NotIgnored
+	case idx >= 0 && g.dirOnly[idx]:
+		return IgnoredAsDirectory
+	default:
+		return IgnoredAsFile
+	}
+}
+
+// IsTrulyIgnored returns true only if the path "f" is targeted by a pattern
+// and not re-included by a later negation, that is when MatchesPathHow
+// reports Match
+func (g *GitIgnore) IsTrulyIgnored(f string) bool {
+	return g.matchesPath(f) == Match
+}
+
+// NegationPatterns returns the source text of the negated patterns, which
+// re-include paths, in the order they were compiled
+func (g *GitIgnore) NegationPatterns() []string {
+	return g.sourcesWhere(true)
+}
+
+// PositivePatterns returns the source text of the patterns which are not
+// negated, in the order they were compiled
+func (g *GitIgnore) PositivePatterns() []string {
+	return g.sourcesWhere(false)
+}
+
+// sourcesWhere returns the sources of the patterns with the given negation
+func (g *GitIgnore) sourcesWhere(negate bool) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var sources []string
+	for idx, source := range g.sources {
+		if g.negate[idx] == negate {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// MatchesArchiveEntry returns true if the tar or zip archive entry "name"
+// is ignored, by its own patterns or through an ignored parent. Archive names are taken to be relative to the root of the
+// ignore file: a leading "./" and any "." and ".." components are cleaned
+// up, and a trailing slash marks the entry as a directory so that
+// directory-only patterns apply. Like most extractors, ".." components
+// which would escape the root are dropped.
+func (g *GitIgnore) MatchesArchiveEntry(name string) bool {
+	name = filepath.ToSlash(name)
+	isDir := strings.HasSuffix(name, "/")
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	return g.hierarchicalRelResult(name, isDir) == Match
+}
+
+// Uncovered returns the paths among "paths" which no pattern targets at
+// all. Unlike a NonMatch from MatchesPathHow this excludes paths which are
+// only targeted by a negation, so that re-included paths are never
+// reported as uncovered.
+func (g *GitIgnore) Uncovered(paths []string) []string {
+	var uncovered []string
+	for _, f := range paths {
+		if !g.coversPath(f) {
+			uncovered = append(uncovered, f)
+		}
+	}
+	return uncovered
+}
+
+// coversPath returns true if any pattern targets the path "f"
+func (g *GitIgnore) coversPath(f string) bool {
+	f, isDir := g.relPath(f)
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for idx := range g.patterns {
+		if g.ruleMatches(idx, f, isDir, nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortedPatterns returns the source text of all patterns in lexicographic
+// order, as a canonical view for analysis. Matching is not affected and
+// still honours the order in which the patterns were compiled.
+func (g *GitIgnore) SortedPatterns() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	sorted := append([]string(nil), g.sources...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// NormalizedRules returns the rules of g in a canonical gitignore form, so
+// that the interpretation of this library can be compared with git's own.
+// Rule 3 trailing spaces are removed, anchored patterns always carry a
+// leading slash, and directory-only patterns and negations keep their
+// markers. The rules of a nested set, see Merge, are anchored below their
+// directory. A rule which is repeated later is only listed at its last
+// occurrence, which is the one that takes precedence.
+func (g *GitIgnore) NormalizedRules() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	var normalized []string
+	seen := map[string]bool{}
+	for idx := len(g.sources) - 1; idx >= 0; idx-- {
+		rule := g.normalizedRule(idx)
+		if !seen[rule] {
+			seen[rule] = true
+			normalized = append(normalized, rule)
+		}
+	}
+	for i, j := 0, len(normalized)-1; i < j; i, j = i+1, j-1 {
+		normalized[i], normalized[j] = normalized[j], normalized[i]
+	}
+	return normalized
+}
+
+// normalizedRule returns the canonical form of the rule at "idx"
+func (g *GitIgnore) normalizedRule(idx int) string {
+	body := strings.TrimPrefix(g.sources[idx], "!")
+	if g.dirOnly[idx] {
+		body = strings.TrimSuffix(body, "/")
+	}
+	if strings.Contains(body, "/") && !strings.HasPrefix(body, "/") && !strings.HasPrefix(body, "**/") {
+		body = "/" + body
+	}
+	// The rules of a nested set are anchored below its directory
+	if dir := g.dirs[idx]; dir != "" {
+		if !strings.HasPrefix(body, "/") && !strings.HasPrefix(body, "**/") {
+			body = "**/" + body
+		}
+		body = "/" + dir + "/" + strings.TrimPrefix(body, "/")
+	}
+	if g.dirOnly[idx] {
+		body += "/"
+	}
+	if g.negate[idx] {
+		body = "!" + body
 	}
-	return matchesPath
+	return body
 }
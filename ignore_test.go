@@ -4,10 +4,15 @@ package ignore
 import (
 	"os"
 
+	"errors"
+	"io"
 	"io/ioutil"
 	"path/filepath"
+	"regexp"
 
+	"bufio"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -22,9 +27,9 @@ const (
 // it a file with the name "fname" and content "content"
 func writeFileToTestDir(fname, content string) {
 	testDirPath := "." + string(filepath.Separator) + TEST_DIR
-	testFilePath := testDirPath + string(filepath.Separator) + fname
+	testFilePath := testDirPath + string(filepath.Separator) + filepath.FromSlash(fname)
 
-	_ = os.MkdirAll(testDirPath, 0755)
+	_ = os.MkdirAll(filepath.Dir(testFilePath), 0755)
 	_ = ioutil.WriteFile(testFilePath, []byte(content), os.ModePerm)
 }
 
@@ -40,25 +45,25 @@ func TestCompileIgnoreLines(test *testing.T) {
 
 	// MatchesPath
 	// Paths which are targeted by the above "lines"
-	assert.Equal(test, Match, object.MatchesPath("abc/def/child"), "abc/def/child should match")
-	assert.Equal(test, Match, object.MatchesPath("a/b/c/d"), "a/b/c/d should match")
+	assert.Equal(test, Match, object.matchesPath("abc/def/child"), "abc/def/child should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/c/d"), "a/b/c/d should match")
 
 	// Paths which are not targeted by the above "lines"
-	assert.Equal(test, NonMatch, object.MatchesPath("abc"), "abc should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("def"), "def should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("bd"), "bd should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("abc"), "abc should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("def"), "def should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("bd"), "bd should not match")
 
 	object, error = CompileIgnoreLines("abc/def", "a/b/c", "b")
 	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
 
 	// Paths which are targeted by the above "lines"
-	assert.Equal(test, Match, object.MatchesPath("abc/def/child"), "abc/def/child should match")
-	assert.Equal(test, Match, object.MatchesPath("a/b/c/d"), "a/b/c/d should match")
+	assert.Equal(test, Match, object.matchesPath("abc/def/child"), "abc/def/child should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/c/d"), "a/b/c/d should match")
 
 	// Paths which are not targeted by the above "lines"
-	assert.Equal(test, NonMatch, object.MatchesPath("abc"), "abc should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("def"), "def should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("bd"), "bd should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("abc"), "abc should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("def"), "def should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("bd"), "bd should not match")
 }
 
 // Validate the invalid files
@@ -77,9 +82,9 @@ func TestCompileIgnoreLines_EmptyFile(test *testing.T) {
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, NonMatch, object.MatchesPath("a"), "should not match any path")
-	assert.Equal(test, NonMatch, object.MatchesPath("a/b"), "should not match any path")
-	assert.Equal(test, NonMatch, object.MatchesPath(".foobar"), "should not match any path")
+	assert.Equal(test, NonMatch, object.matchesPath("a"), "should not match any path")
+	assert.Equal(test, NonMatch, object.matchesPath("a/b"), "should not match any path")
+	assert.Equal(test, NonMatch, object.matchesPath(".foobar"), "should not match any path")
 }
 
 // Validate the correct handling of the negation operator "!"
@@ -97,10 +102,10 @@ func TestCompileIgnoreLines_HandleIncludePattern(test *testing.T) {
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("./test_fixtures/a"), "a should match")
-	assert.Equal(test, Match, object.MatchesPath("./test_fixtures/foo/baz"), "foo/baz should match")
-	assert.Equal(test, Negation, object.MatchesPath("./test_fixtures/foo"), "foo should negate match")
-	assert.Equal(test, Negation, object.MatchesPath("./test_fixtures/foo/bar"), "/foo/bar should negate match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/a"), "a should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/foo/baz"), "foo/baz should match")
+	assert.Equal(test, Negation, object.matchesPath("./test_fixtures/foo"), "foo should negate match")
+	assert.Equal(test, Negation, object.matchesPath("./test_fixtures/foo/bar"), "/foo/bar should negate match")
 }
 
 // Validate the correct handling of comments and empty lines
@@ -123,8 +128,8 @@ abc/def
 	assert.NotNil(test, object, "object should not be nil")
 
 	assert.Equal(test, 2, len(object.patterns), "should have two regex pattern")
-	assert.Equal(test, NonMatch, object.MatchesPath("abc/abc"), "/abc/abc should not match")
-	assert.Equal(test, Match, object.MatchesPath("abc/def"), "/abc/def should match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/abc/abc"), "/abc/abc should not match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/abc/def"), "/abc/def should match")
 }
 
 // Validate the correct handling of leading / chars
@@ -142,10 +147,10 @@ d/e/f
 	assert.NotNil(test, object, "object should not be nil")
 
 	assert.Equal(test, 3, len(object.patterns), "should have 3 regex patterns")
-	assert.Equal(test, Match, object.MatchesPath("a/b/c"), "a/b/c should match")
-	assert.Equal(test, Match, object.MatchesPath("a/b/c/d"), "a/b/c/d should match")
-	assert.Equal(test, Match, object.MatchesPath("d/e/f"), "d/e/f should match")
-	assert.Equal(test, Match, object.MatchesPath("g"), "g should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/c"), "a/b/c should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/c/d"), "a/b/c/d should match")
+	assert.Equal(test, Match, object.matchesPath("d/e/f"), "d/e/f should match")
+	assert.Equal(test, Match, object.matchesPath("g"), "g should match")
 }
 
 // Validate the correct handling of files starting with # or !
@@ -162,12 +167,12 @@ file.txt
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("#file.txt"), "#file.txt should match")
-	assert.Equal(test, Match, object.MatchesPath("!file.txt"), "!file.txt should match")
-	assert.Equal(test, Match, object.MatchesPath("a/!file.txt"), "a/!file.txt should match")
-	assert.Equal(test, Match, object.MatchesPath("file.txt"), "file.txt should match")
-	assert.Equal(test, Match, object.MatchesPath("a/file.txt"), "a/file.txt should match")
-	assert.Equal(test, NonMatch, object.MatchesPath("file2.txt"), "file2.txt should not match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/#file.txt"), "#file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/!file.txt"), "!file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/a/!file.txt"), "a/!file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/file.txt"), "file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/a/file.txt"), "a/file.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/file2.txt"), "file2.txt should not match")
 
 }
 
@@ -182,9 +187,9 @@ Documentation/*.html
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("./test_fixtures/Documentation/git.html"), "Documentation/git.html should match")
-	assert.Equal(test, NonMatch, object.MatchesPath("./test_fixtures/Documentation/ppc/ppc.html"), "Documentation/ppc/ppc.html should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("./test_fixtures/tools/perf/Documentation/perf.html"), "tools/perf/Documentation/perf.html should not match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/Documentation/git.html"), "Documentation/git.html should match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/Documentation/ppc/ppc.html"), "Documentation/ppc/ppc.html should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/tools/perf/Documentation/perf.html"), "tools/perf/Documentation/perf.html should not match")
 }
 
 // Validate the correct handling of "**"
@@ -199,10 +204,58 @@ bar
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("foo"), "foo should match")
-	assert.Equal(test, Match, object.MatchesPath("baz/foo"), "baz/foo should match")
-	assert.Equal(test, Match, object.MatchesPath("bar"), "bar should match")
-	assert.Equal(test, Match, object.MatchesPath("baz/bar"), "baz/bar should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/foo"), "foo should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/baz/foo"), "baz/foo should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/bar"), "bar should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/baz/bar"), "baz/bar should match")
+}
+
+// Validate that a leading "**/" matches in all directories like the bare
+// pattern does [Rule 9 i]
+func TestCompileIgnoreLines_HandleLeadingDoubleStar(test *testing.T) {
+	object, error := CompileIgnoreLines("**/foo")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	bare, _ := CompileIgnoreLines("foo")
+
+	for _, f := range []string{"foo", "a/foo", "a/b/foo", "foo/x", "a/foo/"} {
+		assert.Equal(test, Match, object.matchesPath(f), f+" should match")
+		assert.Equal(test, bare.matchesPath(f), object.matchesPath(f), f+" should match like foo")
+	}
+	for _, f := range []string{"barfoo", "a/barfoo", "foobar", "a/foo.txt", "a/b/barfoo"} {
+		assert.Equal(test, NonMatch, object.matchesPath(f), f+" should not match")
+		assert.Equal(test, bare.matchesPath(f), object.matchesPath(f), f+" should not match foo either")
+	}
+
+	// The directories may be followed by more components
+	object, error = CompileIgnoreLines("**/foo/bar")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("foo/bar"), "foo/bar should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/foo/bar"), "a/b/foo/bar should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/xfoo/bar"), "a/xfoo/bar should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo/x/bar"), "foo/x/bar should not match")
+}
+
+// Validate that a middle "/**/" matches zero or more directories [Rule 9]
+func TestCompileIgnoreLines_HandleMiddleDoubleStar(test *testing.T) {
+	object, error := CompileIgnoreLines("a/**/b")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("a/b"), "a/b should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/b"), "a/x/b should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/y/b"), "a/x/y/b should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/b/c"), "a/x/b/c should match below a/x/b")
+	assert.Equal(test, NonMatch, object.matchesPath("ab"), "ab should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/bc/d"), "a/bc/d should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/xb"), "a/xb should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("ax/b"), "ax/b should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("x/a/b"), "x/a/b should not match")
+
+	// Several middle "**" in a pattern
+	object, error = CompileIgnoreLines("a/**/b/**/c.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("a/b/c.txt"), "a/b/c.txt should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/b/y/z/c.txt"), "a/x/b/y/z/c.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/bc.txt"), "a/bc.txt should not match")
 }
 
 // Validate the correct handling of leading slash
@@ -216,8 +269,8 @@ func TestCompileIgnoreLines_HandleLeadingSlashPath(test *testing.T) {
 	assert.Nil(test, error, "error should be nil")
 	assert.NotNil(test, object, "object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("./test_fixtures/hello.c"), "hello.c should match")
-	assert.Equal(test, NonMatch, object.MatchesPath("./test_fixtures/foo/hello.c"), "foo/hello.c should not match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/hello.c"), "hello.c should match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/foo/hello.c"), "foo/hello.c should not match")
 }
 
 func ExampleCompileIgnoreLines() {
@@ -235,9 +288,9 @@ func ExampleCompileIgnoreLines() {
 	fmt.Println(ignoreObject.MatchesPath("test/foo.js"))
 
 	// Output:
-	// 0
-	// 0
-	// 1
+	// true
+	// true
+	// false
 }
 
 func TestCompileIgnoreLines_CheckNestedDotFiles(test *testing.T) {
@@ -255,9 +308,9 @@ func TestCompileIgnoreLines_CheckNestedDotFiles(test *testing.T) {
 	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
 	assert.NotNil(test, object, "returned object should not be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("external/foobar/angular.foo.css"), "external/foobar/angular.foo.css")
-	assert.Equal(test, Match, object.MatchesPath("external/barfoo/.gitignore"), "external/barfoo/.gitignore")
-	assert.Equal(test, Match, object.MatchesPath("external/barfoo/.bower.json"), "external/barfoo/.bower.json")
+	assert.Equal(test, Match, object.matchesPath("external/foobar/angular.foo.css"), "external/foobar/angular.foo.css")
+	assert.Equal(test, Match, object.matchesPath("external/barfoo/.gitignore"), "external/barfoo/.gitignore")
+	assert.Equal(test, Match, object.matchesPath("external/barfoo/.bower.json"), "external/barfoo/.bower.json")
 }
 
 func TestCompileIgnoreLines_CarriageReturn(test *testing.T) {
@@ -265,22 +318,1764 @@ func TestCompileIgnoreLines_CarriageReturn(test *testing.T) {
 	object, error := CompileIgnoreLines(lines...)
 	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("abc/def/child"), "abc/def/child should match")
-	assert.Equal(test, Match, object.MatchesPath("a/b/c/d"), "a/b/c/d should match")
+	assert.Equal(test, Match, object.matchesPath("abc/def/child"), "abc/def/child should match")
+	assert.Equal(test, Match, object.matchesPath("a/b/c/d"), "a/b/c/d should match")
 
-	assert.Equal(test, NonMatch, object.MatchesPath("abc"), "abc should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("def"), "def should not match")
-	assert.Equal(test, NonMatch, object.MatchesPath("bd"), "bd should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("abc"), "abc should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("def"), "def should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("bd"), "bd should not match")
 }
 
 func TestCompileIgnoreLines_WindowsPath(test *testing.T) {
 	if runtime.GOOS != "windows" {
-		return
+		test.Skip("Windows paths are only matched on Windows")
 	}
 	lines := []string{"abc/def", "a/b/c", "b"}
 	object, error := CompileIgnoreLines(lines...)
 	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
 
-	assert.Equal(test, Match, object.MatchesPath("abc\\def\\child"), "abc\\def\\child should match")
-	assert.Equal(test, Match, object.MatchesPath("a\\b\\c\\d"), "a\\b\\c\\d should match")
+	assert.Equal(test, Match, object.matchesPath("abc\\def\\child"), "abc\\def\\child should match")
+	assert.Equal(test, Match, object.matchesPath("a\\b\\c\\d"), "a\\b\\c\\d should match")
+}
+
+// Validate patterns written with Windows backslashes
+func TestCompileIgnoreLines_WindowsPattern(test *testing.T) {
+	if runtime.GOOS != "windows" {
+		test.Skip("a backslash only separates path components on Windows")
+	}
+	object, error := CompileIgnoreLines("build\\*.obj", "out\\", "\\#tmp", "docs\\a\\ ")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build\\a.obj"), "build\\a.obj should match")
+	assert.Equal(test, Match, object.matchesPath("build/a.obj"), "build/a.obj should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build\\sub\\a.obj"), "build\\sub\\a.obj should not match")
+	assert.Equal(test, Match, object.matchesPath("out\\"), "out\\ should match")
+	assert.Equal(test, Match, object.matchesPath("out\\x.txt"), "out\\x.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("out"), "the file out should not match")
+	assert.Equal(test, Match, object.matchesPath("#tmp"), "#tmp should match")
+	assert.Equal(test, Match, object.matchesPath("docs\\a "), "docs\\a<space> should match")
+}
+
+// Validate the policy for backslashes in patterns, which separate path
+// components on Windows and escape the next character elsewhere
+func TestCompileIgnoreLines_BackslashPolicy(test *testing.T) {
+	object, error := CompileIgnoreLines(`build\temp`, `\!keep`, `\#notes`, `trail\ `)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	// The escapes are honoured everywhere
+	assert.Equal(test, Match, object.matchesPath("!keep"), "!keep should match")
+	assert.Equal(test, Match, object.matchesPath("#notes"), "#notes should match")
+	assert.Equal(test, Match, object.matchesPath("trail "), "trail<space> should match")
+	assert.Equal(test, []bool{false, false, false, false}, object.negate, "no pattern should be a negation")
+
+	if runtime.GOOS == "windows" {
+		assert.Equal(test, Match, object.matchesPath("build/temp"), "build/temp should match")
+		assert.Equal(test, Match, object.matchesPath(`build\temp`), "build\\temp should match")
+		assert.Equal(test, Match, object.matchesPath("build/temp/x.o"), "build/temp/x.o should match")
+		assert.Equal(test, NonMatch, object.matchesPath("buildtemp"), "buildtemp should not match")
+	} else {
+		assert.Equal(test, Match, object.matchesPath("buildtemp"), "an escaped t should be a literal t")
+		assert.Equal(test, NonMatch, object.matchesPath("build/temp"), "build/temp should not match")
+	}
+}
+
+// Validate stripping a known root from absolute paths
+func TestMatchesPathTrimPrefix(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.True(test, object.MatchesPathTrimPrefix("/var/repo/", "/var/repo/a.log"), "/var/repo/a.log should match")
+	assert.True(test, object.MatchesPathTrimPrefix("/var/repo", "/var/repo/sub/a.log"), "/var/repo/sub/a.log should match")
+	assert.False(test, object.MatchesPathTrimPrefix("/var/repo/", "/var/repo/a.txt"), "/var/repo/a.txt should not match")
+	assert.False(test, object.MatchesPathTrimPrefix("/var/repo", "/var/repository/a.log"), "/var/repository/a.log is not under /var/repo")
+
+	// The root prefix
+	object, error = CompileIgnoreLines("/a.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.MatchesPathTrimPrefix("/", "/a.log"), "/a.log should match under the root")
+	assert.False(test, object.MatchesPathTrimPrefix("/", "/sub/a.log"), "/sub/a.log should not match the anchored pattern")
+	assert.False(test, object.MatchesPathTrimPrefix("/", "a.log"), "a relative path is not under the root")
+
+	// A negation cannot re-include a file of an ignored directory
+	object, error = CompileIgnoreLines("build/", "!build/keep.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.MatchesPathTrimPrefix("/var/repo", "/var/repo/build/keep.txt"), "build/keep.txt should be ignored through build")
+	assert.False(test, object.MatchesPathTrimPrefix("/var/repo", "/var/repo/src/keep.txt"), "src/keep.txt should not be ignored")
+}
+
+// Validate that the StrictGit option rejects non-git syntax
+func TestCompileIgnoreLinesWithOptions_StrictGit(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{StrictGit: true}, "*.log", "*.{js,ts}")
+	assert.Nil(test, object, "object should be nil")
+	assert.NotNil(test, error, "brace expansion should be rejected")
+
+	object, error = CompileIgnoreLinesWithOptions(Options{StrictGit: true}, "# {comment}", `\{literal\}`)
+	assert.Nil(test, error, "comments and escaped braces should be accepted")
+	assert.NotNil(test, object, "object should not be nil")
+
+	object, error = CompileIgnoreLines("*.{js,ts}")
+	assert.Nil(test, error, "braces are accepted without StrictGit")
+	assert.NotNil(test, object, "object should not be nil")
+}
+
+// Validate that a negation overrides several earlier positive matches
+func TestCompileIgnoreLines_NegationAfterMultipleMatches(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "debug.log", "!debug.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Negation, object.matchesPath("debug.log"), "debug.log should be re-included")
+	assert.Equal(test, Match, object.matchesPath("foo.log"), "foo.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo.txt"), "foo.txt should not match")
+}
+
+// Validate that a trailing slash restricts a pattern to directories
+func TestCompileIgnoreLines_HandleDirOnly(test *testing.T) {
+	object, error := CompileIgnoreLines("build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build/"), "build/ should match")
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+	assert.Equal(test, Match, object.matchesPath("src/build/x.o"), "src/build/x.o should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build"), "the file build should not match")
+}
+
+// Validate the handling of "\n", "\r\n" and lone "\r" line separators
+func TestCompileIgnoreFile_LineSeparators(test *testing.T) {
+	writeFileToTestDir("test.gitignore", "a.txt\rb.txt\r\nc.txt\nd.txt\r")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFile("./test_fixtures/test.gitignore")
+	assert.Nil(test, error, "error should be nil")
+	assert.NotNil(test, object, "object should not be nil")
+
+	assert.Equal(test, 4, len(object.patterns), "should have 4 regex patterns")
+	for _, f := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		assert.Equal(test, Match, object.matchesPath("./test_fixtures/"+f), f+" should match")
+	}
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/e.txt"), "e.txt should not match")
+}
+
+// Validate "MostSpecificMatch()"
+func TestMostSpecificMatch(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "debug.log", "*")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	index, ok := object.MostSpecificMatch("debug.log")
+	assert.True(test, ok, "debug.log should match")
+	assert.Equal(test, 1, index, "debug.log is the most specific rule")
+
+	index, ok = object.MostSpecificMatch("foo.log")
+	assert.True(test, ok, "foo.log should match")
+	assert.Equal(test, 0, index, "*.log is the most specific rule")
+
+	object, error = CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	_, ok = object.MostSpecificMatch("foo.txt")
+	assert.False(test, ok, "foo.txt should not match")
+}
+
+// Validate that "Merge()" evaluates the rules of all sets in order
+func TestMerge(test *testing.T) {
+	project, error := CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	user, error := CompileIgnoreLines("!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	object := Merge(project, user)
+	assert.Equal(test, 2, len(object.patterns), "should have 2 regex patterns")
+	assert.Equal(test, Negation, object.matchesPath("keep.log"), "keep.log should be re-included")
+	assert.Equal(test, Match, object.matchesPath("debug.log"), "debug.log should match")
+
+	// The later set wins
+	object = Merge(user, project)
+	assert.Equal(test, Match, object.matchesPath("keep.log"), "keep.log should match")
+}
+
+// Validate merging the patterns of a nested ignore file
+func TestMerge_Nested(test *testing.T) {
+	writeFileToTestDir("repo/.gitignore", "*.tmp\n/dist\n")
+	writeFileToTestDir("repo/a/.gitignore", "build/\n!keep.tmp\n/out\n")
+	defer cleanupTestDir()
+
+	root, error := CompileIgnoreFile("./test_fixtures/repo/.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+	nested, error := CompileIgnoreFile("./test_fixtures/repo/a/.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+	object := Merge(root, nested)
+
+	// The root patterns apply to the whole tree
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/x.tmp"), "x.tmp should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/b/x.tmp"), "b/x.tmp should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/dist"), "dist should match")
+
+	// The nested patterns only apply below their directory
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a/build/"), "a/build/ should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a/sub/build/x.o"), "a/sub/build/x.o should match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/build/"), "build/ should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/b/build/x.o"), "b/build/x.o should not match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/a/keep.tmp"), "a/keep.tmp should be re-included")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/keep.tmp"), "keep.tmp should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a/out"), "a/out should match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/out"), "out should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/a/b/out"), "a/b/out should not match")
+
+	assert.Equal(test, []string{"*.tmp", "/dist", "/a/**/build/", "!/a/**/keep.tmp", "/a/out"}, object.NormalizedRules(), "nested rules should be anchored")
+	// Without the root patterns nothing can match outside of the nested directory
+	empty, _ := CompileIgnoreLines()
+	empty.basePath = root.basePath
+	onlyNested := Merge(empty, nested)
+	assert.False(test, onlyNested.HasMatchesUnder("test_fixtures/repo/b/c"), "nothing can match under b/c")
+	assert.True(test, onlyNested.HasMatchesUnder("test_fixtures/repo/a/c"), "build/ can match under a/c")
+	assert.True(test, onlyNested.HasMatchesUnder("test_fixtures/repo"), "the nested patterns can match under the root")
+	assert.Equal(test, []RuleMatch{{Index: 2, Loc: []int{2, 7}}}, object.AllMatchIndices("test_fixtures/repo/a/build"), "locations should be relative to the base path")
+}
+
+// Validate "CompileIgnoreFiles()"
+func TestCompileIgnoreFiles(test *testing.T) {
+	writeFileToTestDir("home/global.ignore", "*.tmp\nbuild/\n")
+	writeFileToTestDir("repo/.gitignore", "!keep.tmp\n/dist\n")
+	writeFileToTestDir("repo/sub/.gitignore", "!build/\n")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFiles(
+		"./test_fixtures/home/global.ignore",
+		"./test_fixtures/repo/.gitignore",
+		"./test_fixtures/repo/sub/.gitignore",
+	)
+	assert.Nil(test, error, "error from CompileIgnoreFiles should be nil")
+	assert.Equal(test, filepath.Join("test_fixtures", "repo"), object.basePath, "paths should be matched from the repository")
+
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a.tmp"), "a.tmp should match through the global file")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/x/a.tmp"), "x/a.tmp should match through the global file")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/keep.tmp"), "keep.tmp should be re-included by the later file")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/dist"), "dist should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/build/"), "build/ should match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/sub/build/"), "sub/build/ should be re-included")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/sub/dist"), "sub/dist should not match")
+
+	// A global file followed by the .gitignore of the repository alone
+	object, error = CompileIgnoreFiles("./test_fixtures/home/global.ignore", "./test_fixtures/repo/.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFiles should be nil")
+	assert.Equal(test, filepath.Join("test_fixtures", "repo"), object.basePath, "paths should be matched from the repository")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a.tmp"), "a.tmp should match through the global file")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/keep.tmp"), "keep.tmp should be re-included by the later file")
+
+	// The repository root holds a later nested file
+	object, error = CompileIgnoreFiles("./test_fixtures/repo/.gitignore", "./test_fixtures/repo/sub/.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFiles should be nil")
+	assert.Equal(test, filepath.Join("test_fixtures", "repo"), object.basePath, "paths should be matched from the repository")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/dist"), "dist should match")
+
+	// The later file wins
+	writeFileToTestDir("repo/local.ignore", "keep.tmp\n")
+	object, error = CompileIgnoreFiles("./test_fixtures/home/global.ignore", "./test_fixtures/repo/.gitignore", "./test_fixtures/repo/local.ignore")
+	assert.Nil(test, error, "error from CompileIgnoreFiles should be nil")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/keep.tmp"), "keep.tmp should match")
+
+	_, error = CompileIgnoreFiles("./test_fixtures/repo/.gitignore", "./test_fixtures/missing.ignore")
+	assert.True(test, os.IsNotExist(errors.Unwrap(error)), "the error should wrap the missing file error")
+	assert.True(test, strings.HasPrefix(error.Error(), "./test_fixtures/missing.ignore: "), "the error should name the file")
+}
+
+// Validate "CompileLayered()"
+func TestCompileLayered(test *testing.T) {
+	writeFileToTestDir("home/global.ignore", "*.log\n*.tmp\n!important.log\n")
+	writeFileToTestDir("repo/.git/info/exclude", "important.log\n/local/\n")
+	writeFileToTestDir("repo/.gitignore", "!keep.log\n")
+	writeFileToTestDir("repo/sub/.gitignore", "!*.tmp\n")
+	defer cleanupTestDir()
+
+	object, error := CompileLayered(
+		"./test_fixtures/home/global.ignore",
+		"./test_fixtures/repo/.git/info/exclude",
+		"./test_fixtures/repo/.gitignore",
+		"./test_fixtures/repo/sub/.gitignore",
+	)
+	assert.Nil(test, error, "error from CompileLayered should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a.log"), "a.log should match through the global file")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/keep.log"), "keep.log should be re-included by the repository")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/x/keep.log"), "x/keep.log should be re-included by the repository")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/important.log"), "the exclude file should win over the global one")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/local/"), "the exclude file should apply from the work tree")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/a.tmp"), "a.tmp should match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/repo/sub/a.tmp"), "sub/a.tmp should be re-included")
+	assert.True(test, object.IgnoresPath("test_fixtures/repo/local/keep.log"), "local/keep.log is below an ignored directory")
+
+	// Missing or omitted global and exclude files are skipped
+	object, error = CompileLayered("", "./test_fixtures/missing", "./test_fixtures/repo/.gitignore")
+	assert.Nil(test, error, "error from CompileLayered should be nil")
+	assert.Equal(test, []string{"!keep.log"}, object.sources, "only the repository file should be compiled")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/a.log"), "a.log should not match")
+
+	// Without repository files the work tree holds the exclude file
+	object, error = CompileLayered("./test_fixtures/home/global.ignore", "./test_fixtures/repo/.git/info/exclude")
+	assert.Nil(test, error, "error from CompileLayered should be nil")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/repo/local/"), "local/ should match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/repo/x/local/"), "x/local/ should not match")
+
+	_, error = CompileLayered("", "", "./test_fixtures/repo/missing.gitignore")
+	assert.NotNil(test, error, "a missing repository file should be an error")
+}
+
+// Validate "CompileIgnoreTree()"
+func TestCompileIgnoreTree(test *testing.T) {
+	writeFileToTestDir("tree/.gitignore", "*.log\nignored/\n")
+	writeFileToTestDir("tree/-first/.gitignore", "!*.log\n")
+	writeFileToTestDir("tree/a/.gitignore", "!debug.log\n")
+	writeFileToTestDir("tree/a/b/.gitignore", "debug.log\n")
+	writeFileToTestDir("tree/ignored/.gitignore", "!*.log\n")
+	writeFileToTestDir("tree/.git/.gitignore", "!*.log\n")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreTree("./test_fixtures/tree")
+	assert.Nil(test, error, "error from CompileIgnoreTree should be nil")
+	assert.Equal(test, []string{"*.log", "ignored/", "!/-first/**/*.log", "!/a/**/debug.log", "/a/b/**/debug.log"}, object.NormalizedRules(), "the files should be read from the top down")
+
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/debug.log"), "debug.log should match")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/c/debug.log"), "c/debug.log should match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/tree/a/debug.log"), "a/debug.log should be re-included")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/tree/a/c/debug.log"), "a/c/debug.log should be re-included")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/a/b/debug.log"), "a/b/debug.log should match again")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/a/b/c/debug.log"), "a/b/c/debug.log should match again")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/a/other.log"), "a/other.log should match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/tree/-first/x.log"), "-first/x.log should be re-included")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/tree/ignored/x.log"), "ignored/x.log should match")
+
+	// Errors name the offending file
+	writeFileToTestDir("tree/a/b/.gitignore", "a**b\n")
+	_, error = CompileIgnoreTree("./test_fixtures/tree")
+	assert.EqualError(test, error, filepath.Join("test_fixtures", "tree", "a", "b", ".gitignore")+`: line 1: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+
+	_, error = CompileIgnoreTree("./test_fixtures/missing")
+	assert.NotNil(test, error, "a missing root should fail")
+}
+
+// Validate "HasMatchesUnder()"
+func TestHasMatchesUnder(test *testing.T) {
+	object, error := CompileIgnoreLines("/other/*", "!/build")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.False(test, object.HasMatchesUnder("build"), "nothing can match under build")
+	assert.True(test, object.HasMatchesUnder("other"), "/other/* can match under other")
+	assert.True(test, object.HasMatchesUnder("other/sub"), "/other/* can match under other/sub")
+	assert.True(test, object.HasMatchesUnder(""), "/other/* can match under the root")
+
+	object, error = CompileIgnoreLines("/other/*", "*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.HasMatchesUnder("build"), "*.log can match anywhere")
+
+	object, error = CompileIgnoreLines("/**/tmp")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.HasMatchesUnder("build"), "/**/tmp can match anywhere")
+
+	// Flags such as case insensitivity are honoured
+	object, error = CompileIgnoreLinesWithOptions(Options{RegexpFlags: "(?i)"}, "/Other/*")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.True(test, object.IgnoresPath("other/x"), "other/x should be ignored")
+	assert.True(test, object.HasMatchesUnder("other"), "/Other/* can match under other with (?i)")
+
+	// So are the syntax path.Match does not share
+	object, error = CompileIgnoreLines("/[!a-z]dir/*")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.IgnoresPath("1dir/x"), "1dir/x should be ignored")
+	assert.True(test, object.HasMatchesUnder("1dir"), "/[!a-z]dir/* can match under 1dir")
+
+	object, error = CompileIgnoreLinesWithOptions(Options{AnchorEnd: true}, "/build$")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.True(test, object.IgnoresPath("build"), "build should be ignored")
+	assert.True(test, object.HasMatchesUnder("build"), "/build$ can match build")
+	assert.False(test, object.HasMatchesUnder("src"), "/build$ cannot match under src")
+}
+
+// Validate that a trailing "/*" targets the direct children of a directory
+// and reaches their descendants only through them
+func TestCompileIgnoreLines_HandleTrailingStar(test *testing.T) {
+	object, error := CompileIgnoreLines("build/*")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build/a"), "build/a should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build"), "build should not match")
+	assert.True(test, object.patterns[0].get().MatchString("build/a"), "the regexp should match a direct child")
+	assert.False(test, object.patterns[0].get().MatchString("build/sub/file"), "the regexp should match exactly one segment")
+
+	// Descendants are only ignored through their ignored directory
+	assert.Equal(test, Match, object.matchesPath("build/sub/"), "build/sub/ should match")
+	assert.Equal(test, Match, object.matchesPath("build/sub/file"), "build/sub/file should match through build/sub")
+	assert.True(test, object.IgnoresPath("build/sub/file"), "build/sub/file should be ignored")
+	assert.Equal(test, IgnoredAsFile, object.IgnoreKind("build/sub", true), "build/sub should be ignored itself")
+	assert.Equal(test, IgnoredViaAncestor, object.IgnoreKind("build/sub/file", false), "build/sub/file should be ignored via build/sub")
+	assert.Equal(test, NotIgnored, object.IgnoreKind("build", true), "build should not be ignored")
+
+	// A directory-only "build/*/" still ignores the contents of the directories
+	object, error = CompileIgnoreLines("build/*/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("build/sub/"), "build/sub/ should match")
+	assert.Equal(test, Match, object.matchesPath("build/sub/file"), "build/sub/file should match through build/sub")
+	assert.Equal(test, NonMatch, object.matchesPath("build/a"), "the build/a file should not match")
+	assert.True(test, object.IgnoresPath("build/sub/file"), "build/sub/file should be ignored")
+}
+
+// Validate the RegexpFlags option
+func TestCompileIgnoreLinesWithOptions_RegexpFlags(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{RegexpFlags: "(?i)"}, "*.PNG", "Build/")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("photo.png"), "photo.png should match")
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+
+	object, error = CompileIgnoreLines("*.PNG")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("photo.png"), "photo.png should not match by default")
+
+	for _, flags := range []string{"i", "(?i", "(?x)", "(?i).*", "(a)"} {
+		object, error = CompileIgnoreLinesWithOptions(Options{RegexpFlags: flags}, "*.PNG")
+		assert.Nil(test, object, "object should be nil for flags "+flags)
+		assert.NotNil(test, error, "flags "+flags+" should be rejected")
+	}
+}
+
+// Validate the case-insensitive mode
+func TestCompileIgnoreLinesCaseInsensitive(test *testing.T) {
+	object, error := CompileIgnoreLinesCaseInsensitive("*.PNG", "/Build/", "!/Build/KEEP[a-c].TXT")
+	assert.Nil(test, error, "error from CompileIgnoreLinesCaseInsensitive should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("a.png"), "a.png should match")
+	assert.Equal(test, Match, object.matchesPath("dir/A.Png"), "dir/A.Png should match")
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+	assert.Equal(test, NonMatch, object.matchesPath("src/build/x.o"), "the anchor should still apply")
+	assert.Equal(test, Negation, object.matchesPath("build/keepB.txt"), "bracket expressions should ignore case too")
+	assert.True(test, object.HasMatchesUnder("BUILD"), "anything may match under BUILD")
+
+	object, error = CompileIgnoreLines("*.PNG")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("a.png"), "a.png should not match by default")
+	assert.Equal(test, Match, object.matchesPath("a.PNG"), "a.PNG should match by default")
+
+	// Combined with other flags
+	object, error = CompileIgnoreLinesWithOptions(Options{CaseInsensitive: true, RegexpFlags: "(?s)"}, "*.PNG")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, Match, object.matchesPath("a.png"), "a.png should match")
+}
+
+// Validate "IsTrulyIgnored()"
+func TestIsTrulyIgnored(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("debug.log"), "debug.log should match")
+	assert.True(test, object.IsTrulyIgnored("debug.log"), "debug.log should be ignored")
+
+	assert.Equal(test, Negation, object.matchesPath("keep.log"), "keep.log should be re-included")
+	assert.False(test, object.IsTrulyIgnored("keep.log"), "keep.log should not be ignored")
+
+	assert.Equal(test, NonMatch, object.matchesPath("main.go"), "main.go should not match")
+	assert.False(test, object.IsTrulyIgnored("main.go"), "main.go should not be ignored")
+}
+
+// Validate the trailing "$" marker of the AnchorEnd option
+func TestCompileIgnoreLinesWithOptions_AnchorEnd(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{AnchorEnd: true}, "build$", `price\$`)
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build"), "build should match")
+	assert.Equal(test, Match, object.matchesPath("src/build"), "src/build should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build/sub"), "build/sub should not match")
+	assert.Equal(test, Match, object.matchesPath("price$"), "price$ should match")
+
+	object, error = CompileIgnoreLines("build")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("build/sub"), "build/sub should match without the marker")
+}
+
+// Validate that the directory holding the ignore file is never matched
+func TestCompileIgnoreFile_MatchBasePath(test *testing.T) {
+	writeFileToTestDir("test.gitignore", `
+*
+.*
+**
+`)
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFile("./test_fixtures/test.gitignore")
+	assert.Nil(test, error, "error should be nil")
+	assert.NotNil(test, object, "object should not be nil")
+
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures"), "the base path should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/"), "the base path should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures"), "the base path should not match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/a"), "a should match")
+}
+
+// Validate "NegationPatterns()" and "PositivePatterns()"
+func TestNegationAndPositivePatterns(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "# comment", "!keep.log", "build/", "!/vendor/keep  \r", `\!bang`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, []string{"!keep.log", "!/vendor/keep"}, object.NegationPatterns(), "unexpected negation patterns")
+	assert.Equal(test, []string{"*.log", "build/", `\!bang`}, object.PositivePatterns(), "unexpected positive patterns")
+}
+
+// Validate the ExpandEnv option
+func TestCompileIgnoreLinesWithOptions_ExpandEnv(test *testing.T) {
+	_ = os.Setenv("GO_GIT_IGNORE_CACHE", "cache")
+	defer os.Unsetenv("GO_GIT_IGNORE_CACHE")
+
+	object, error := CompileIgnoreLinesWithOptions(Options{ExpandEnv: true}, "/$GO_GIT_IGNORE_CACHE/", "${GO_GIT_IGNORE_CACHE}.db")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, Match, object.matchesPath("cache/x"), "cache/x should match")
+	assert.Equal(test, Match, object.matchesPath("cache.db"), "cache.db should match")
+
+	mapping := func(name string) string { return "tmp" }
+	object, error = CompileIgnoreLinesWithOptions(Options{ExpandEnv: true, EnvMapping: mapping}, "$DIR/*.o")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, Match, object.matchesPath("tmp/a.o"), "tmp/a.o should match")
+
+	object, error = CompileIgnoreLines("/$GO_GIT_IGNORE_CACHE/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("cache/x"), "variables are not expanded by default")
+}
+
+// Validate the regexp evaluation counting of "MatchesPathStats()"
+func TestMatchesPathStats(test *testing.T) {
+	writeFileToTestDir("test.gitignore", `
+*.log
+*.o
+/build
+`)
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFile("./test_fixtures/test.gitignore")
+	assert.Nil(test, error, "error should be nil")
+
+	var stats MatchStats
+	assert.Equal(test, NonMatch, object.MatchesPathStats("./test_fixtures/a.txt", &stats), "a.txt should not match")
+	assert.Equal(test, 3, stats.Evaluations, "every rule should be evaluated once")
+
+	assert.Equal(test, Match, object.MatchesPathStats("./test_fixtures/a.log", &stats), "a.log should match")
+	assert.Equal(test, 3, stats.Evaluations, "the counters should be reset for each call")
+
+	// The base path itself is short-circuited without evaluating the rules
+	assert.Equal(test, NonMatch, object.MatchesPathStats("./test_fixtures", &stats), "the base path should not match")
+	assert.Equal(test, 0, stats.Evaluations, "no rule should be evaluated")
+}
+
+// Validate that asterisks other than the three "**" forms [Rule 9 iv] are
+// rejected, naming the offending sequence
+func TestCompileIgnoreLines_RejectInvalidStars(test *testing.T) {
+	for line, sequence := range map[string]string{
+		"a/***/b": "***",
+		"**foo":   "**",
+		"foo**":   "**",
+		"***":     "***",
+		"/**foo/": "**",
+		"!a/**b":  "**",
+	} {
+		_, errs := CompileIgnoreLinesWithError("*.log", line)
+		if assert.Equal(test, 1, len(errs), line+" should be rejected") {
+			assert.Equal(test, 2, errs[0].LineNumber, "the error should name the line of "+line)
+			assert.Contains(test, errs[0].Error(), fmt.Sprintf("invalid %q sequence", sequence), "unexpected error for "+line)
+		}
+	}
+
+	for _, line := range []string{"**/foo", "foo/**", "a/**/b", "**", "/**/foo", "!**/foo", "*foo*", `a\**b`} {
+		_, errs := CompileIgnoreLinesWithError("*.log", line)
+		assert.Nil(test, errs, line+" should be accepted")
+	}
+}
+
+// Validate that a dangling trailing backslash is rejected with a readable
+// error, an escaped one is a literal backslash
+func TestCompileIgnoreLines_RejectTrailingBackslash(test *testing.T) {
+	if runtime.GOOS == "windows" {
+		test.Skip("a backslash separates path components on Windows")
+	}
+	for _, line := range []string{`x\`, `a/b\`, `x\\\`, `!x\`} {
+		_, errs := CompileIgnoreLinesWithError("*.log", line)
+		if assert.Equal(test, 1, len(errs), line+" should be rejected") {
+			assert.EqualError(test, errs[0], fmt.Sprintf("line 2: pattern %q ends with an unescaped backslash", line), "unexpected error for "+line)
+		}
+	}
+
+	object, errs := CompileIgnoreLinesWithError(`x\\`, `a\ `)
+	assert.Nil(test, errs, "escaped backslashes and spaces should be accepted")
+	assert.Equal(test, Match, object.matchesPath(`x\`), `x\ should match`)
+	assert.Equal(test, Match, object.matchesPath("a "), `"a " should match`)
+}
+
+// Validate the classification of consecutive asterisks
+func TestCompileIgnoreLines_ClassifyDoubleStar(test *testing.T) {
+	for _, line := range []string{"a**b", "a/**b", "a**/b", "a/***/b"} {
+		object, error := CompileIgnoreLines(line)
+		assert.Nil(test, object, "object should be nil for "+line)
+		assert.NotNil(test, error, line+" should be rejected")
+	}
+
+	object, error := CompileIgnoreLines("a/**/b")
+	assert.Nil(test, error, "a/**/b should be accepted")
+	assert.Equal(test, Match, object.matchesPath("a/b"), "a/b should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/b"), "a/x/b should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/y/b"), "a/x/y/b should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/xb"), "a/xb should not match")
+
+	object, error = CompileIgnoreLines("**/b")
+	assert.Nil(test, error, "**/b should be accepted")
+	assert.Equal(test, Match, object.matchesPath("b"), "b should match")
+	assert.Equal(test, Match, object.matchesPath("x/y/b"), "x/y/b should match")
+	assert.Equal(test, NonMatch, object.matchesPath("xb"), "xb should not match")
+
+	object, error = CompileIgnoreLines("a/**")
+	assert.Nil(test, error, "a/** should be accepted")
+	assert.Equal(test, Match, object.matchesPath("a/x/y"), "a/x/y should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a"), "a should not match")
+
+	object, error = CompileIgnoreLines(`a\**b`)
+	assert.Nil(test, error, "an escaped asterisk should be accepted")
+
+	// Repeated "**" components collapse into one
+	for _, line := range []string{"a/**/**/b", "a/**/**/**/b", "**/**/a/b", "/a/**/**"} {
+		object, error = CompileIgnoreLines(line)
+		assert.Nil(test, error, line+" should be accepted")
+		assert.Equal(test, Match, object.matchesPath("a/b/c"), line+" should match a/b/c")
+		assert.Equal(test, NonMatch, object.matchesPath("x/c"), line+" should not match x/c")
+	}
+	object, _ = CompileIgnoreLines("a/**/**/b")
+	assert.Equal(test, Match, object.matchesPath("a/b"), "a/**/**/b should match a/b")
+	assert.Equal(test, Match, object.matchesPath("a/x/y/b"), "a/**/**/b should match a/x/y/b")
+}
+
+// Validate the handling of ambiguous leading "#" and "!" sequences
+func TestCompileIgnoreLines_HandleLeadingHashBang(test *testing.T) {
+	tests := []struct {
+		line     string
+		patterns int
+		path     string
+		expected int
+	}{
+		{"#!x", 1, "#!x", Match},    // A comment, only "*" applies
+		{"!#x", 2, "#x", Negation},  // A negation of the literal "#x"
+		{"!#x", 2, "x", Match},      // ... which does not target "x"
+		{`\#!x`, 2, "#!x", Match},   // The literal "#!x"
+		{"!!x", 2, "!x", Negation},  // A negation of the literal "!x"
+		{`!\!x`, 2, "!x", Negation}, // The same with an explicit escape
+		{`\!#x`, 2, "!#x", Match},   // The literal "!#x"
+		{`\!#x`, 2, "#x", Match},    // ... which does not re-include "#x"
+	}
+	for _, t := range tests {
+		object, error := CompileIgnoreLines("*", t.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, t.patterns, len(object.patterns), "unexpected number of patterns for "+t.line)
+		assert.Equal(test, t.expected, object.matchesPath(t.path), "unexpected result for "+t.path+" with "+t.line)
+	}
+}
+
+// Validate the unescaping of a single leading "\#", "\!" or "\\"
+func TestCompileIgnoreLines_HandleEscapedLeadingChars(test *testing.T) {
+	tests := []struct {
+		line     string
+		path     string
+		expected int
+	}{
+		{`\!important.txt`, "!important.txt", Match}, // Not a negation
+		{`\!important.txt`, "important.txt", NonMatch},
+		{`\!important.txt`, "a/!important.txt", Match},
+		{`\#notes`, "#notes", Match},
+		{`\#notes`, "notes", NonMatch},
+		{`\\back`, `\back`, Match},
+		{`\\back`, "back", NonMatch},
+		{`\!a+b(1).txt`, "!a+b(1).txt", Match}, // Metacharacters stay literal
+		{`\!a+b(1).txt`, "!aab1.txt", NonMatch},
+		{`\#*.md`, "#x.md", Match}, // Wildcards still apply
+		{"#comment", "#comment", NonMatch},
+	}
+	for _, t := range tests {
+		if filepath.Separator == '\\' && strings.HasPrefix(t.line, `\\`) {
+			// A backslash separates path components on Windows
+			continue
+		}
+		object, error := CompileIgnoreLines(t.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, t.expected, object.matchesPath(t.path), "unexpected result for "+t.path+" with "+t.line)
+	}
+
+	// A plain "!" still negates, and an escape after it is literal
+	object, error := CompileIgnoreLines("*", "!foo", `!\!bar`, `!\#baz`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Negation, object.matchesPath("foo"), "foo should be re-included")
+	assert.Equal(test, Negation, object.matchesPath("!bar"), "!bar should be re-included")
+	assert.Equal(test, Negation, object.matchesPath("#baz"), "#baz should be re-included")
+	assert.Equal(test, Match, object.matchesPath("bar"), "bar should match")
+
+	// A normal comment is still skipped
+	object, error = CompileIgnoreLines("#comment", `\#comment`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{`\#comment`}, object.sources, "only the escaped line should be a pattern")
+}
+
+// Validate "Options.BangBang"
+func TestCompileIgnoreLines_BangBang(test *testing.T) {
+	object, error := CompileIgnoreLinesWithOptions(Options{BangBang: true}, "*.txt", "!!foo", `\!bar`, "!keep.txt", "!!!baz")
+	assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+	assert.Equal(test, Match, object.matchesPath("!foo"), "!foo should match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo"), "foo should not match")
+	assert.Equal(test, Match, object.matchesPath("!bar"), "the standard escape should still work")
+	assert.Equal(test, Match, object.matchesPath("!!baz"), "!!baz should match")
+	assert.Equal(test, Negation, object.matchesPath("keep.txt"), "a single ! should still negate")
+	assert.Equal(test, Match, object.matchesPath("a.txt"), "a.txt should match")
+	assert.Equal(test, []bool{false, false, false, true, false}, object.negate, "only !keep.txt should be a negation")
+
+	// Git reads "!!foo" as a negation of "!foo"
+	object, error = CompileIgnoreLines("*", "!!foo")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Negation, object.matchesPath("!foo"), "!foo should be re-included by default")
+}
+
+// composeAccents stands in for norm.NFC.String, composing the accented
+// letters the tests below use
+var composeAccents = strings.NewReplacer("e\u0301", "\u00e9", "E\u0301", "\u00c9").Replace
+
+// Validate "Options.NormalizeUnicode" with names in NFC and NFD form
+func TestCompileIgnoreLines_NormalizeUnicode(test *testing.T) {
+	nfc, nfd := "caf\u00e9", "cafe\u0301"
+
+	object, error := CompileIgnoreLines(nfc)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath(nfc), "the same form should match")
+	assert.Equal(test, NonMatch, object.matchesPath(nfd), "another form should not match by default")
+
+	for _, pattern := range []string{nfc, nfd} {
+		object, error = CompileIgnoreLinesWithOptions(Options{NormalizeUnicode: composeAccents}, pattern, "!"+pattern+"/keep")
+		assert.Nil(test, error, "error from CompileIgnoreLinesWithOptions should be nil")
+		for _, f := range []string{nfc, nfd, "src/" + nfd, nfd + "/x"} {
+			assert.Equal(test, Match, object.matchesPath(f), fmt.Sprintf("%q should match %q", f, pattern))
+		}
+		assert.Equal(test, Negation, object.matchesPath(nfc+"/keep"), "the negation should apply in any form")
+		assert.Equal(test, NonMatch, object.matchesPath("cafe"), "cafe should not match")
+	}
+
+	// The other queries normalize the path too
+	object, _ = CompileIgnoreLinesWithOptions(Options{NormalizeUnicode: composeAccents}, "/"+nfc+"/*.log")
+	assert.True(test, object.HasMatchesUnder(nfd), "a directory in another form may hold matches")
+	assert.Equal(test, []RuleMatch{{Index: 0, Loc: []int{0, len(nfc) + 6}}}, object.AllMatchIndices(nfd+"/a.log"), "the rule should match the normalized path")
+}
+
+// Validate "IgnoredRelativeTo()"
+func TestIgnoredRelativeTo(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	object.basePath = "/repo"
+
+	paths := []string{"/repo/a.log", "/repo/src/b.log", "/repo/src/keep.log", "/repo/src/main.go"}
+	assert.Equal(test,
+		[]string{filepath.FromSlash("../a.log"), "b.log"},
+		object.IgnoredRelativeTo("/repo/src", paths),
+		"unexpected display paths")
+	assert.Equal(test,
+		[]string{"a.log", filepath.FromSlash("src/b.log")},
+		object.IgnoredRelativeTo("/repo", paths),
+		"unexpected display paths")
+}
+
+// Validate patterns consisting only of a negated wildcard
+func TestCompileIgnoreLines_HandleNegatedWildcard(test *testing.T) {
+	object, error := CompileIgnoreLines("*", "!*.keep")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Negation, object.matchesPath("a.keep"), "a.keep should be re-included")
+	assert.Equal(test, Negation, object.matchesPath("src/.keep"), "src/.keep should be re-included")
+	assert.Equal(test, Match, object.matchesPath("a.txt"), "a.txt should match")
+
+	// Without a prior ignore the negation has no effect
+	object, error = CompileIgnoreLines("!*.keep")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("a.keep"), "a.keep should not match")
+	assert.Equal(test, NonMatch, object.matchesPath(".keep"), ".keep should not match")
+}
+
+// Validate "MatchesArchiveEntry()"
+func TestMatchesArchiveEntry(test *testing.T) {
+	object, error := CompileIgnoreLines("build/", "*.log", "/secret")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.True(test, object.MatchesArchiveEntry("./build/"), "./build/ should match")
+	assert.True(test, object.MatchesArchiveEntry("./build/x.o"), "./build/x.o should match")
+	assert.False(test, object.MatchesArchiveEntry("./build"), "the file ./build should not match")
+	assert.False(test, object.MatchesArchiveEntry("src/main.go"), "src/main.go should not match")
+	assert.True(test, object.MatchesArchiveEntry("./a/../b.log"), "./a/../b.log should match")
+	assert.True(test, object.MatchesArchiveEntry("src/../secret"), "src/../secret should match")
+	assert.True(test, object.MatchesArchiveEntry("../secret"), "../secret is cleaned to secret")
+	assert.False(test, object.MatchesArchiveEntry("./"), "the root should not match")
+
+	// A negation cannot re-include an entry of an ignored directory
+	object, error = CompileIgnoreLines("build/", "!build/keep.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.MatchesArchiveEntry("./build/keep.txt"), "./build/keep.txt should be ignored through build")
+}
+
+// Validate "Uncovered()"
+func TestUncovered(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "!*.md")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	paths := []string{"debug.log", "keep.log", "README.md", "main.go", "src/util.go"}
+	assert.Equal(test, []string{"main.go", "src/util.go"}, object.Uncovered(paths), "unexpected uncovered paths")
+}
+
+// Validate "SortedPatterns()"
+func TestSortedPatterns(test *testing.T) {
+	first, error := CompileIgnoreLines("*.log", "build/", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	second, error := CompileIgnoreLines("!keep.log", "*.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	sorted := []string{"!keep.log", "*.log", "build/"}
+	assert.Equal(test, sorted, first.SortedPatterns(), "unexpected sorted patterns")
+	assert.Equal(test, sorted, second.SortedPatterns(), "unexpected sorted patterns")
+
+	// Matching still depends on the original order
+	assert.Equal(test, Negation, first.matchesPath("keep.log"), "keep.log should be re-included")
+	assert.Equal(test, Match, second.matchesPath("keep.log"), "keep.log should match")
+	assert.Equal(test, []string{"*.log", "build/", "!keep.log"}, first.sources, "the patterns should keep their order")
+}
+
+// Validate that paths outside of the base path never match
+func TestCompileIgnoreFile_MatchOutsideBasePath(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	object.basePath = "/repo/a"
+
+	assert.Equal(test, Match, object.matchesPath("/repo/a/x.log"), "/repo/a/x.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("/repo/b/x.log"), "/repo/b/x.log should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("/repo/x.log"), "/repo/x.log should not match")
+}
+
+// Validate "CompileRepoExclude()"
+func TestCompileRepoExclude(test *testing.T) {
+	writeFileToTestDir(".git/info/exclude", "*.log\n/build\n")
+	defer cleanupTestDir()
+
+	object, error := CompileRepoExclude("./test_fixtures")
+	assert.Nil(test, error, "error should be nil")
+	assert.NotNil(test, object, "object should not be nil")
+
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/a.log"), "a.log should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/build"), "build should match")
+	assert.Equal(test, Match, object.matchesPath("./test_fixtures/src/b.log"), "src/b.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/src/build"), "src/build should not match")
+
+	// A repository without an exclude file
+	object, error = CompileRepoExclude("./test_fixtures/.git")
+	assert.Nil(test, error, "error should be nil")
+	assert.NotNil(test, object, "object should not be nil")
+	assert.Equal(test, 0, len(object.patterns), "should have no patterns")
+}
+
+// Validate a re-excluded subpath of a re-included directory
+func TestCompileIgnoreLines_HandleReExcludedSubpath(test *testing.T) {
+	object, error := CompileIgnoreLines("build/", "!build/keep/", "build/keep/tmp/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+	assert.Equal(test, Negation, object.matchesPath("build/keep/"), "the last pattern targeting build/keep/ should be the negation")
+	assert.Equal(test, Negation, object.matchesPath("build/keep/file"), "the last pattern targeting build/keep/file should be the negation")
+	assert.Equal(test, Match, object.matchesPath("build/keep/tmp/"), "build/keep/tmp/ should match")
+	assert.Equal(test, Match, object.matchesPath("build/keep/tmp/x"), "build/keep/tmp/x should match")
+
+	// The negation is void below the ignored build [Rule 4]
+	for _, f := range []string{"build/keep/", "build/keep/file", "build/keep/tmp/x"} {
+		assert.True(test, object.IgnoresPath(f), f+" should be ignored through build")
+	}
+}
+
+// Validate that negations are void below an ignored directory [Rule 4]
+func TestIgnoresPath_ExcludedAncestor(test *testing.T) {
+	// The directory itself is ignored, so nothing in it can be re-included
+	object, error := CompileIgnoreLines("build/", "!build/keep.txt", "!build/sub/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Negation, object.matchesPath("build/keep.txt"), "the negation should target build/keep.txt")
+	assert.True(test, object.IgnoresPath("build/keep.txt"), "build/keep.txt should be ignored through build")
+	assert.False(test, object.IncludesPath("build/keep.txt"), "build/keep.txt should not be re-included")
+	assert.True(test, object.IgnoresPath("build/sub/x"), "build/sub/x should be ignored through build")
+	assert.True(test, object.IgnoresPath("build/"), "build/ should be ignored")
+
+	// Only the contents are ignored, so the directory is still walked
+	object, error = CompileIgnoreLines("build/*", "!build/keep.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.False(test, object.IgnoresPath("build/keep.txt"), "build/keep.txt should not be ignored")
+	assert.True(test, object.IncludesPath("build/keep.txt"), "build/keep.txt should be re-included")
+	assert.True(test, object.IgnoresPath("build/other.txt"), "build/other.txt should be ignored")
+	assert.False(test, object.IgnoresPath("build/"), "build/ should not be ignored")
+
+	// A re-included directory makes the negations below it effective again
+	object, error = CompileIgnoreLines("build/*", "!build/keep/", "build/keep/*", "!build/keep/a.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.True(test, object.IncludesPath("build/keep/a.txt"), "build/keep/a.txt should be re-included")
+	assert.True(test, object.IgnoresPath("build/keep/b.txt"), "build/keep/b.txt should be ignored")
+	assert.True(test, object.IgnoresPath("build/x.o"), "build/x.o should be ignored")
+}
+
+// Validate an allow-list which only keeps the go files of a tree
+func TestCompileIgnoreLines_HandleAllowList(test *testing.T) {
+	object, error := CompileIgnoreLines("*", "!*.go", "!*/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	dirs := []string{"src", "src/a", "src/a/b"}
+	for _, dir := range dirs {
+		assert.Equal(test, Negation, object.matchesPath(dir+"/"), dir+" should be traversable")
+	}
+
+	files := map[string]bool{
+		"main.go":              true,
+		"README.md":            false,
+		"src/a/main.go":        true,
+		"src/a/readme.txt":     false,
+		"src/a/b/deep_test.go": true,
+		"src/a/b/Makefile":     false,
+	}
+	for file, included := range files {
+		assert.Equal(test, included, object.IncludesPath(file), file+" has the wrong inclusion")
+		assert.Equal(test, !included, object.IgnoresPath(file), file+" has the wrong exclusion")
+	}
+}
+
+// Validate that a trailing "/**" matches everything inside the directory,
+// at any depth, but not the directory itself [Rule 9 ii]
+func TestCompileIgnoreLines_HandleTrailingDoubleStar(test *testing.T) {
+	object, error := CompileIgnoreLines("abc/**")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	for _, f := range []string{"abc/x", "abc/y/z", "abc/x/", "abc/.hidden"} {
+		assert.Equal(test, Match, object.matchesPath(f), f+" should match")
+	}
+	for _, f := range []string{"abc", "abc/", "abcd/x", "xabc/y", "x/abc/y"} {
+		assert.Equal(test, NonMatch, object.matchesPath(f), f+" should not match")
+	}
+	assert.False(test, object.IgnoresPath("abc"), "the abc file should not be ignored")
+	assert.True(test, object.IgnoresPath("abc/y/z"), "abc/y/z should be ignored")
+}
+
+// Validate that a trailing "/**/" matches everything inside, like "/**"
+func TestCompileIgnoreLines_HandleTrailingDoubleStarSlash(test *testing.T) {
+	object, error := CompileIgnoreLines("a/**/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("a/x"), "a/x should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/"), "a/x/ should match")
+	assert.Equal(test, Match, object.matchesPath("a/x/y"), "a/x/y should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a"), "a should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/"), "a/ should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("b/a/x"), "b/a/x should not match")
+	assert.False(test, object.Rules()[0].DirOnly, "the rule should not be directory-only")
+}
+
+// Validate that a pattern with a slash other than a trailing one is
+// anchored to the base path, while a pattern without one matches at any
+// depth [Rules 6-8]
+func TestCompileIgnoreLines_HandleInteriorSlash(test *testing.T) {
+	object, error := CompileIgnoreLines("doc/file.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("doc/file.txt"), "doc/file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("doc/file.txt/x"), "the contents of doc/file.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("sub/doc/file.txt"), "sub/doc/file.txt should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("xdoc/file.txt"), "xdoc/file.txt should not match")
+	assert.Equal(test, []string{"/doc/file.txt"}, object.NormalizedRules(), "the pattern should be anchored")
+
+	object, error = CompileIgnoreLines("file.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("file.txt"), "file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("doc/file.txt"), "doc/file.txt should match")
+	assert.Equal(test, Match, object.matchesPath("sub/doc/file.txt"), "sub/doc/file.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("myfile.txt"), "myfile.txt should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("doc/myfile.txt"), "doc/myfile.txt should not match")
+
+	// Only whole path components match a pattern without a slash
+	for pattern, paths := range map[string][]string{
+		"foo":    {"barfoo", "a/barfoo", "foobar"},
+		"build/": {"mybuild/", "a/mybuild/", "mybuild/x"},
+		"a?c":    {"xabc", "x/xabc"},
+		"*.log":  {"a.log.txt"},
+	} {
+		object, error = CompileIgnoreLines(pattern)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		for _, f := range paths {
+			assert.Equal(test, NonMatch, object.matchesPath(f), f+" should not match "+pattern)
+		}
+	}
+	object, _ = CompileIgnoreLines("a?c")
+	assert.Equal(test, Match, object.matchesPath("x/abc"), "x/abc should match a?c")
+
+	// A trailing slash does not anchor
+	object, error = CompileIgnoreLines("doc/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("sub/doc/"), "sub/doc/ should match")
+	object, error = CompileIgnoreLines("a/b/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("a/b/"), "a/b/ should match")
+	assert.Equal(test, NonMatch, object.matchesPath("x/a/b/"), "x/a/b/ should not match")
+}
+
+// Validate anchored directory-only patterns
+func TestCompileIgnoreLines_HandleAnchoredDirOnly(test *testing.T) {
+	object, error := CompileIgnoreLines("/build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("build/"), "the directory build should match")
+	assert.Equal(test, Match, object.matchesPath("build/x"), "build/x should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build"), "the file build should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("sub/build/"), "sub/build/ should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("sub/build/x"), "sub/build/x should not match")
+}
+
+// Validate that an escaped "." is not escaped twice
+func TestCompileIgnoreLines_HandleEscapedDot(test *testing.T) {
+	object, error := CompileIgnoreLines(`a\.b`, `c.d`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, 2, len(object.patterns), "should have 2 regex patterns")
+
+	assert.Equal(test, Match, object.matchesPath("a.b"), "a.b should match")
+	assert.Equal(test, NonMatch, object.matchesPath("aXb"), "aXb should not match")
+	assert.Equal(test, NonMatch, object.matchesPath(`a\.b`), `a\.b should not match`)
+	assert.Equal(test, Match, object.matchesPath("c.d"), "c.d should match")
+	assert.Equal(test, NonMatch, object.matchesPath("cXd"), "cXd should not match")
+}
+
+// Validate that the base path is normalized at construction
+func TestCompileIgnoreFile_CleanBasePath(test *testing.T) {
+	writeFileToTestDir("test.gitignore", "*.log\n/build\n")
+	writeFileToTestDir(".git/info/exclude", "*.log\n/build\n")
+	defer cleanupTestDir()
+
+	fromFile, error := CompileIgnoreFile("./test_fixtures/../test_fixtures/./test.gitignore")
+	assert.Nil(test, error, "error should be nil")
+	fromExclude, error := CompileRepoExclude("./test_fixtures/")
+	assert.Nil(test, error, "error should be nil")
+
+	for _, object := range []*GitIgnore{fromFile, fromExclude} {
+		assert.Equal(test, "test_fixtures", object.basePath, "the base path should be clean")
+		assert.Equal(test, Match, object.matchesPath("test_fixtures/a.log"), "a.log should match")
+		assert.Equal(test, Match, object.matchesPath("./test_fixtures/build"), "build should match")
+		assert.Equal(test, NonMatch, object.matchesPath("./test_fixtures/src/build"), "src/build should not match")
+		assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/"), "the base path should not match")
+	}
+
+	// An ignore file in the current directory
+	cwd, _ := os.Getwd()
+	os.Chdir(TEST_DIR)
+	defer os.Chdir(cwd)
+	object, error := CompileIgnoreFile("test.gitignore")
+	assert.Nil(test, error, "error should be nil")
+	assert.Equal(test, ".", object.basePath, "the base path should be the current directory")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+	assert.Equal(test, Match, object.matchesPath("./build"), "build should match")
+	assert.Equal(test, NonMatch, object.matchesPath("src/build"), "src/build should not match")
+}
+
+// Validate that only unescaped trailing spaces are stripped [Rule 3]
+func TestCompileIgnoreLines_HandleEscapedTrailingSpaces(test *testing.T) {
+	object, error := CompileIgnoreLines(`foo\ `)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("foo "), "foo<space> should match")
+	assert.Equal(test, Match, object.matchesPath("dir/foo "), "dir/foo<space> should match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo"), "foo should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo  "), "foo<space><space> should not match")
+
+	// Only the escaped space is kept
+	object, error = CompileIgnoreLines(`foo\    `)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("foo "), "foo<space> should match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo"), "foo should not match")
+
+	object, error = CompileIgnoreLines("foo   ")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("foo"), "foo should match")
+	assert.Equal(test, NonMatch, object.matchesPath("foo "), "foo<space> should not match")
+
+	// An escaped backslash does not escape the space after it
+	object, error = CompileIgnoreLines(`foo\\ `)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath(`foo\`), "foo<backslash> should match")
+	assert.Equal(test, NonMatch, object.matchesPath(`foo\ `), "foo<backslash><space> should not match")
+}
+
+// Validate that a line holding only an escaped space matches a file named
+// with a single space [Rule 3]
+func TestCompileIgnoreLines_HandleLoneEscapedSpace(test *testing.T) {
+	for _, line := range []string{`\ `, `\   `, "\\ \r"} {
+		object, error := CompileIgnoreLines(line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, []string{`\ `}, object.sources, "the line should compile to one rule")
+		assert.Equal(test, Match, object.matchesPath(" "), "<space> should match")
+		assert.Equal(test, Match, object.matchesPath("dir/ "), "dir/<space> should match")
+		assert.Equal(test, Match, object.matchesPath(" /file"), "the contents of <space> should match")
+		assert.Equal(test, NonMatch, object.matchesPath(" a"), "<space>a should not match")
+		assert.Equal(test, NonMatch, object.matchesPath("a "), "a<space> should not match")
+		assert.Equal(test, NonMatch, object.matchesPath("  "), "<space><space> should not match")
+		assert.Equal(test, NonMatch, object.matchesPath("dir/a "), "dir/a<space> should not match")
+		assert.Equal(test, NonMatch, object.matchesPath("a"), "a should not match")
+	}
+
+	object, error := CompileIgnoreReader(strings.NewReader("*.log\n\\ \n!keep.log\n"))
+	assert.Nil(test, error, "error from CompileIgnoreReader should be nil")
+	assert.Equal(test, Match, object.matchesPath(" "), "<space> should match")
+	assert.Equal(test, 3, len(object.patterns), "every line should compile")
+}
+
+// Validate that escaped metacharacters match their literal filenames
+func TestCompileIgnoreLines_EscapedMetacharacters(test *testing.T) {
+	for _, tc := range []struct{ line, name string }{
+		{`\*`, "*"},
+		{`\?`, "?"},
+		{`\[`, "["},
+		{`\]`, "]"},
+	} {
+		object, error := CompileIgnoreLines(tc.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, Match, object.matchesPath(tc.name), fmt.Sprintf("%s should match %q", tc.line, tc.name))
+		assert.Equal(test, Match, object.matchesPath("dir/"+tc.name), fmt.Sprintf("%s should match in a subdirectory", tc.line))
+		assert.Equal(test, NonMatch, object.matchesPath("a"), fmt.Sprintf("%s should not match a", tc.line))
+		assert.Equal(test, NonMatch, object.matchesPath(tc.name+"a"), fmt.Sprintf("%s should not match a longer name", tc.line))
+	}
+
+	object, _ := CompileIgnoreLines(`a\*b*`)
+	assert.Equal(test, Match, object.matchesPath("a*b"), "a*b should match")
+	assert.Equal(test, Match, object.matchesPath("a*bc"), "a*bc should match")
+	assert.Equal(test, NonMatch, object.matchesPath("axb"), "axb should not match")
+}
+
+// Validate the "?" wildcard, which matches a single character but no "/"
+func TestCompileIgnoreLines_HandleQuestionMark(test *testing.T) {
+	object, error := CompileIgnoreLines("a?c", "file?.txt")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("abc"), "abc should match")
+	assert.Equal(test, Match, object.matchesPath("dir/a.c"), "dir/a.c should match")
+	assert.Equal(test, NonMatch, object.matchesPath("ac"), "ac should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("abbc"), "abbc should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/c"), "a/c should not match")
+
+	assert.Equal(test, Match, object.matchesPath("file1.txt"), "file1.txt should match")
+	assert.Equal(test, NonMatch, object.matchesPath("file.txt"), "file.txt should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("file12.txt"), "file12.txt should not match")
+
+	object, error = CompileIgnoreLines(`a\?c`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("a?c"), "a?c should match")
+	assert.Equal(test, NonMatch, object.matchesPath("abc"), "abc should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("ac"), "ac should not match")
+}
+
+// Validate bracket expressions such as "[a-z]" and "[!abc]"
+func TestCompileIgnoreLines_HandleBracketClasses(test *testing.T) {
+	for _, tc := range []struct {
+		line       string
+		matches    []string
+		nonMatches []string
+	}{
+		{"*.[oa]", []string{"x.o", "x.a", "dir/lib.a"}, []string{"x.c", "x.oa", "x.", "x.[oa]"}},
+		{"[!a-z].txt", []string{"1.txt", "_.txt", "dir/X.txt"}, []string{"a.txt", "z.txt", "ab.txt", "a/.txt"}},
+		{"[^0-9]", []string{"a", "dir/b"}, []string{"1", "a1"}},
+		{"file[0-9]", []string{"file1"}, []string{"file", "filex", "file12"}},
+		{"x[.]y", []string{"x.y"}, []string{"xzy"}},
+		{"[]a]", []string{"]", "a"}, []string{"b"}},
+		{"[[:digit:]]x", []string{"7x"}, []string{"ax"}},
+		{`[\]]`, []string{"]"}, []string{`\`}},
+		{"a[/]b", nil, []string{"a/b", "a[/]b"}},
+		{"a[!x]b", []string{"a.b"}, []string{"a/b", "axb"}},
+		{"a[+-0]b", []string{"a.b", "a,b"}, []string{"a/b", "a1b"}},
+		{"a[!+-0]b", []string{"a1b"}, []string{"a/b", "a.b"}},
+		{"[abc", []string{"[abc", "dir/[abc"}, []string{"a", "abc"}},
+		{"a]b", []string{"a]b"}, []string{"ab"}},
+	} {
+		object, error := CompileIgnoreLines(tc.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		for _, f := range tc.matches {
+			assert.Equal(test, Match, object.matchesPath(f), fmt.Sprintf("%s should match %q", tc.line, f))
+		}
+		for _, f := range tc.nonMatches {
+			assert.Equal(test, NonMatch, object.matchesPath(f), fmt.Sprintf("%s should not match %q", tc.line, f))
+		}
+	}
+}
+
+// Validate that regexp metacharacters in literal parts match as written
+func TestCompileIgnoreLines_HandleRegexpMetacharacters(test *testing.T) {
+	for _, tc := range []struct {
+		line       string
+		matches    []string
+		nonMatches []string
+	}{
+		{"a+b.txt", []string{"a+b.txt", "dir/a+b.txt"}, []string{"ab.txt", "aab.txt", "a+bxtxt"}},
+		{"(cache)", []string{"(cache)", "(cache)/x", "dir/(cache)"}, []string{"cache", "x/cache"}},
+		{"(temp).log", []string{"(temp).log"}, []string{"temp.log"}},
+		{"foo$", []string{"foo$", "dir/foo$"}, []string{"foo"}},
+		{"price$.csv", []string{"price$.csv"}, []string{"price.csv"}},
+		{"a{2}", []string{"a{2}"}, []string{"aa"}},
+		{"a|b", []string{"a|b"}, []string{"a", "b"}},
+		{"^x", []string{"^x", "dir/^x"}, []string{"x"}},
+		{"/^x", []string{"^x"}, []string{"x", "dir/^x"}},
+		{"a(b", []string{"a(b"}, []string{"ab"}},
+		{`\t`, []string{"t"}, []string{"\t"}},
+	} {
+		object, error := CompileIgnoreLines(tc.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		for _, f := range tc.matches {
+			assert.Equal(test, Match, object.matchesPath(f), fmt.Sprintf("%s should match %q", tc.line, f))
+		}
+		for _, f := range tc.nonMatches {
+			assert.Equal(test, NonMatch, object.matchesPath(f), fmt.Sprintf("%s should not match %q", tc.line, f))
+		}
+	}
+}
+
+// Validate that an unterminated "[" is a literal character
+func TestCompileIgnoreLines_HandleUnterminatedBracket(test *testing.T) {
+	for _, tc := range []struct {
+		line       string
+		matches    []string
+		nonMatches []string
+	}{
+		{"a[b", []string{"a[b", "dir/a[b"}, []string{"ab", "a", "a[", "b"}},
+		{"[", []string{"["}, []string{"a"}},
+		{"a[", []string{"a["}, []string{"a"}},
+		{"a[b*", []string{"a[b", "a[bcd"}, []string{"ab"}},
+		{"[x]y[z", []string{"xy[z"}, []string{"[x]y[z", "xyz"}},
+		{"a[!", []string{"a[!"}, []string{"a"}},
+	} {
+		object, error := CompileIgnoreLines(tc.line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil for "+tc.line)
+		for _, f := range tc.matches {
+			assert.Equal(test, Match, object.matchesPath(f), fmt.Sprintf("%s should match %q", tc.line, f))
+		}
+		for _, f := range tc.nonMatches {
+			assert.Equal(test, NonMatch, object.matchesPath(f), fmt.Sprintf("%s should not match %q", tc.line, f))
+		}
+	}
+}
+
+// Validate that "ReloadFile()" picks up changes to the ignore file
+func TestReloadFile(test *testing.T) {
+	writeFileToTestDir("test.gitignore", "*.log\n")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFile("./test_fixtures/test.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/a.log"), "a.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/a.tmp"), "a.tmp should not match")
+
+	writeFileToTestDir("test.gitignore", "*.tmp\n")
+	error = object.ReloadFile()
+	assert.Nil(test, error, "error from ReloadFile should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/a.log"), "a.log should not match after the reload")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/a.tmp"), "a.tmp should match after the reload")
+
+	// A file which fails to compile leaves the rules in place
+	writeFileToTestDir("test.gitignore", "a**b\n")
+	error = object.ReloadFile()
+	assert.NotNil(test, error, "error from ReloadFile should not be nil")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/a.tmp"), "a.tmp should still match")
+
+	// As does a missing file
+	os.Remove(filepath.Join(TEST_DIR, "test.gitignore"))
+	error = object.ReloadFile()
+	assert.NotNil(test, error, "error from ReloadFile should not be nil")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/a.tmp"), "a.tmp should still match")
+}
+
+// Validate concurrent matching and reloading, run with -race
+func TestReloadFile_Concurrent(test *testing.T) {
+	writeFileToTestDir("test.gitignore", "*.log\n")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreFile("./test_fixtures/test.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f := "test_fixtures/build/a.log"
+		for i := 0; i < 100; i++ {
+			object.matchesPath(f)
+			object.MostSpecificMatch(f)
+			object.Uncovered([]string{f})
+			object.HasMatchesUnder("test_fixtures/build")
+			object.NegationPatterns()
+			object.PositivePatterns()
+			object.SortedPatterns()
+			object.NormalizedRules()
+			object.Rules()
+			object.Minimize()
+			object.CoversPattern("a.log")
+			object.MatchesPathReordered(f, []int{0})
+			object.IgnoreKind(f, false)
+			object.AllMatchIndices(f)
+			object.GroupByDecidingRule([]string{f})
+		}
+	}()
+
+	// Grow and shrink the rule set while it is in use
+	for i := 0; i < 10; i++ {
+		if i%2 == 0 {
+			writeFileToTestDir("test.gitignore", "*.log\n/build/\n!keep.log\n")
+		} else {
+			writeFileToTestDir("test.gitignore", "*.log\n")
+		}
+		assert.Nil(test, object.ReloadFile(), "error from ReloadFile should be nil")
+	}
+	<-done
+}
+
+// Validate that "ReloadFile()" needs a set compiled from a file
+func TestReloadFile_NoFile(test *testing.T) {
+	object, _ := CompileIgnoreLines("*.log")
+	assert.NotNil(test, object.ReloadFile(), "lines have no file to reload")
+	assert.NotNil(test, Merge(object).ReloadFile(), "merged sets have no file to reload")
+}
+
+// Validate "IgnoreKind()"
+func TestIgnoreKind(test *testing.T) {
+	object, error := CompileIgnoreLines("build/", "*.log", "!build/keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, IgnoredAsDirectory, object.IgnoreKind("build", true), "build should be ignored as a directory")
+	assert.Equal(test, IgnoredAsDirectory, object.IgnoreKind("src/build/", false), "a trailing slash marks a directory")
+	assert.Equal(test, NotIgnored, object.IgnoreKind("build", false), "the build file should not be ignored")
+	assert.Equal(test, IgnoredViaAncestor, object.IgnoreKind("build/x.o", false), "build/x.o should be ignored via build")
+	assert.Equal(test, IgnoredViaAncestor, object.IgnoreKind("build/keep.log", false), "negations cannot re-include from build")
+	assert.Equal(test, IgnoredAsFile, object.IgnoreKind("src/a.log", false), "a.log should be ignored as a file")
+	assert.Equal(test, IgnoredAsFile, object.IgnoreKind("logs.log", true), "*.log ignores directories too")
+	assert.Equal(test, NotIgnored, object.IgnoreKind("src/main.go", false), "main.go should not be ignored")
+	assert.Equal(test, NotIgnored, object.IgnoreKind(".", true), "the base path should not be ignored")
+}
+
+// Validate that an interior slash anchors a pattern like a leading one [Rules 7, 8]
+func TestCompileIgnoreLines_InteriorSlashAnchors(test *testing.T) {
+	for _, line := range []string{"foo/bar", "/foo/bar"} {
+		object, error := CompileIgnoreLines(line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+		assert.Equal(test, Match, object.matchesPath("foo/bar"), line+" should match foo/bar")
+		assert.Equal(test, Match, object.matchesPath("foo/bar/baz"), line+" should match inside foo/bar")
+		assert.Equal(test, NonMatch, object.matchesPath("x/foo/bar"), line+" should not match x/foo/bar")
+		assert.Equal(test, NonMatch, object.matchesPath("xfoo/bar"), line+" should not match xfoo/bar")
+		assert.False(test, object.HasMatchesUnder("x"), line+" should have no matches under x")
+		assert.True(test, object.HasMatchesUnder("foo"), line+" should have matches under foo")
+	}
+
+	// A trailing slash alone does not anchor
+	object, _ := CompileIgnoreLines("foo/")
+	assert.Equal(test, Match, object.matchesPath("x/foo/"), "foo/ should match x/foo/")
+	assert.True(test, object.HasMatchesUnder("x"), "foo/ may match under x")
+
+	// Neither does a leading "**/"
+	object, _ = CompileIgnoreLines("**/foo/bar")
+	assert.Equal(test, Match, object.matchesPath("x/foo/bar"), "**/foo/bar should match x/foo/bar")
+}
+
+// Validate "NormalizedRules()"
+func TestNormalizedRules(test *testing.T) {
+	object, error := CompileIgnoreLines(
+		"# comment",
+		"*.log  ",
+		"foo/bar",
+		"/foo/bar",
+		"build/",
+		"src/gen/",
+		"**/tmp",
+		"!/keep.log",
+		"*.log",
+	)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{
+		"/foo/bar",
+		"build/",
+		"/src/gen/",
+		"**/tmp",
+		"!/keep.log",
+		"*.log",
+	}, object.NormalizedRules(), "unexpected normalized rules")
+
+	object, _ = CompileIgnoreLines()
+	assert.Equal(test, 0, len(object.NormalizedRules()), "no rules should be listed")
+}
+
+// Validate "CompileIgnoreDir()"
+func TestCompileIgnoreDir(test *testing.T) {
+	writeFileToTestDir("rules/20-logs.ignore", "!keep.log\nbuild/\n")
+	writeFileToTestDir("rules/10-base.ignore", "*.log\n*.tmp\n")
+	writeFileToTestDir("rules/30-other.txt", "*.go\n")
+	writeFileToTestDir("rules/sub.ignore/a.ignore", "*.go\n")
+	defer cleanupTestDir()
+
+	object, error := CompileIgnoreDir("./test_fixtures/rules/", "*.ignore")
+	assert.Nil(test, error, "error from CompileIgnoreDir should be nil")
+	assert.Equal(test, []string{"*.log", "*.tmp", "!keep.log", "build/"}, object.NormalizedRules(), "files should be merged in sorted order")
+
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/rules/a.log"), "a.log should match")
+	assert.Equal(test, Negation, object.matchesPath("test_fixtures/rules/keep.log"), "keep.log should be negated by the later file")
+	assert.Equal(test, Match, object.matchesPath("test_fixtures/rules/build/x.o"), "build/x.o should match")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/rules/main.go"), "other files should not be read")
+
+	// No matching files yields an empty set
+	object, error = CompileIgnoreDir("./test_fixtures/rules", "*.none")
+	assert.Nil(test, error, "error from CompileIgnoreDir should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("test_fixtures/rules/a.log"), "nothing should match")
+
+	// Errors name the offending file
+	writeFileToTestDir("rules/40-bad.ignore", "a**b\n")
+	_, error = CompileIgnoreDir("./test_fixtures/rules", "*.ignore")
+	assert.EqualError(test, error, filepath.Join("test_fixtures", "rules", "40-bad.ignore")+`: line 1: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+
+	_, error = CompileIgnoreDir("./test_fixtures/rules", "[")
+	assert.NotNil(test, error, "a bad glob should fail")
+}
+
+// Validate that only a leading unescaped "!" negates a pattern [Rule 4]
+func TestCompileIgnoreLines_InteriorBang(test *testing.T) {
+	for _, line := range []string{"foo!bar", `foo\!bar`} {
+		object, error := CompileIgnoreLines(line)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, Match, object.matchesPath("foo!bar"), line+" should match foo!bar")
+		assert.Equal(test, Match, object.matchesPath("dir/foo!bar"), line+" should match dir/foo!bar")
+		assert.Equal(test, NonMatch, object.matchesPath("foobar"), line+" should not match foobar")
+		assert.Equal(test, 0, len(object.NegationPatterns()), line+" should not be a negation")
+	}
+
+	object, error := CompileIgnoreLines("foo*", "!foo")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Negation, object.matchesPath("foo"), "!foo should negate foo")
+	assert.Equal(test, Match, object.matchesPath("foo!"), "!foo should not negate foo!")
+
+	object, error = CompileIgnoreLines(`\!foo!`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, Match, object.matchesPath("!foo!"), `\!foo! should match !foo!`)
+}
+
+// Validate "MatchesPathReordered()"
+func TestMatchesPathReordered(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	result, error := object.MatchesPathReordered("keep.log", []int{0, 1, 2})
+	assert.Nil(test, error, "error from MatchesPathReordered should be nil")
+	assert.Equal(test, Negation, result, "the natural order should negate keep.log")
+
+	result, error = object.MatchesPathReordered("keep.log", []int{1, 2, 0})
+	assert.Nil(test, error, "error from MatchesPathReordered should be nil")
+	assert.Equal(test, Match, result, "a negation before its positive should have no effect")
+	assert.Equal(test, Negation, object.matchesPath("keep.log"), "the rules themselves should not be reordered")
+
+	for _, order := range [][]int{nil, {0, 1}, {0, 1, 1}, {0, 1, 3}, {-1, 0, 1}, {0, 1, 2, 3}} {
+		_, error = object.MatchesPathReordered("keep.log", order)
+		assert.NotNil(test, error, fmt.Sprintf("%v should not be accepted as an order", order))
+	}
+}
+
+// Validate "AllMatchIndices()" with overlapping patterns
+func TestAllMatchIndices(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "*.txt", "logs/", "debug*", "!logs/debug.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.Equal(test, []RuleMatch{
+		{Index: 0, Loc: []int{5, 14}},
+		{Index: 2, Loc: []int{0, 14}},
+		{Index: 3, Loc: []int{5, 14}},
+		{Index: 4, Loc: []int{0, 14}},
+	}, object.AllMatchIndices("logs/debug.log"), "unexpected match locations")
+
+	assert.Equal(test, 0, len(object.AllMatchIndices("src/main.go")), "nothing should match main.go")
+}
+
+// Validate that leading spaces are significant, as in git
+func TestCompileIgnoreLines_LeadingSpaces(test *testing.T) {
+	object, error := CompileIgnoreLines("#comment", "  #x", "  foo", "bar   ", "   ", `\#baz`)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{"  #x", "  foo", "bar", `\#baz`}, object.sources, "only trailing spaces should be trimmed")
+
+	assert.Equal(test, Match, object.matchesPath("  #x"), "  #x should match a file with leading spaces")
+	assert.Equal(test, NonMatch, object.matchesPath("#x"), "  #x should not match #x")
+	assert.Equal(test, Match, object.matchesPath("  foo"), "  foo should match a file with leading spaces")
+	assert.Equal(test, NonMatch, object.matchesPath("foo"), "  foo should not match foo")
+	assert.Equal(test, Match, object.matchesPath("bar"), "trailing spaces should be ignored")
+	assert.Equal(test, Match, object.matchesPath("#baz"), `\#baz should match #baz`)
+	assert.Equal(test, NonMatch, object.matchesPath("#comment"), "#comment should be a comment")
+}
+
+// Validate "CompileIgnoreScanner()" with an enlarged buffer
+func TestCompileIgnoreScanner(test *testing.T) {
+	long := strings.Repeat("a", 100000)
+	writeFileToTestDir("test.gitignore", "*.log\n/"+long+"\n")
+	defer cleanupTestDir()
+
+	// The default buffer is too small for the long line
+	f, error := os.Open(filepath.Join(TEST_DIR, "test.gitignore"))
+	assert.Nil(test, error, "error from Open should be nil")
+	defer f.Close()
+	object, error := CompileIgnoreScanner(bufio.NewScanner(f))
+	assert.Equal(test, bufio.ErrTooLong, error, "the long line should not fit")
+	assert.Nil(test, object, "object should be nil")
+
+	f, error = os.Open(filepath.Join(TEST_DIR, "test.gitignore"))
+	assert.Nil(test, error, "error from Open should be nil")
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 2*len(long))
+	object, error = CompileIgnoreScanner(scanner)
+	assert.Nil(test, error, "error from CompileIgnoreScanner should be nil")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+	assert.Equal(test, Match, object.matchesPath(long), "the long pattern should match")
+	assert.Equal(test, NonMatch, object.matchesPath("x/"+long), "the long pattern should be anchored")
+
+	_, error = CompileIgnoreScanner(bufio.NewScanner(strings.NewReader("*.log\na**b\n")))
+	assert.EqualError(test, error, `line 2: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+}
+
+// GitIgnore must satisfy the IgnoreParser interface
+var _ IgnoreParser = &GitIgnore{}
+
+// Validate that "MatchesPathHow()" reports the deciding pattern
+func TestMatchesPathHow(test *testing.T) {
+	object, error := CompileIgnoreLines("# logs", "*.log", "", "!keep*.log", "keep-not.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Nil(test, object.AddRule(Rule{Pattern: "*.tmp"}), "error from AddRule should be nil")
+
+	for _, tc := range []struct {
+		path   string
+		result int
+		source string
+		line   int
+	}{
+		{"a.log", Match, "*.log", 2},
+		{"keep.log", Negation, "!keep*.log", 4},
+		{"keep-not.log", Match, "keep-not.log", 5},
+		{"build/keep.log", Match, "build/", 6},
+		{"a.tmp", Match, "*.tmp", 0},
+		{"a.go", NonMatch, "", 0},
+	} {
+		result, source, line := object.MatchesPathHow(tc.path)
+		assert.Equal(test, tc.result, result, "unexpected result for "+tc.path)
+		assert.Equal(test, tc.source, source, "unexpected source for "+tc.path)
+		assert.Equal(test, tc.line, line, "unexpected line for "+tc.path)
+	}
+
+	// Line numbers are kept through a file and a merge
+	writeFileToTestDir("how.gitignore", "\n*.log\n\n!keep.log\n")
+	defer cleanupTestDir()
+	file, error := CompileIgnoreFile("./test_fixtures/how.gitignore")
+	assert.Nil(test, error, "error from CompileIgnoreFile should be nil")
+	merged := Merge(object, file)
+	result, source, line := merged.MatchesPathHow("test_fixtures/keep.log")
+	assert.Equal(test, Negation, result, "keep.log should be re-included")
+	assert.Equal(test, "!keep.log", source, "unexpected source")
+	assert.Equal(test, 4, line, "unexpected line")
+}
+
+// Validate that the boolean methods agree with the tri-state result
+func TestIgnoreParser(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	var parser IgnoreParser = object
+	for _, tc := range []struct {
+		path   string
+		result int
+	}{
+		{"a.log", Match},
+		{"keep.log", Negation},
+		{"a.go", NonMatch},
+	} {
+		result, _, _ := object.MatchesPathHow(tc.path)
+		assert.Equal(test, tc.result, result, "unexpected result for "+tc.path)
+		assert.Equal(test, tc.result != NonMatch, parser.MatchesPath(tc.path), "MatchesPath disagrees for "+tc.path)
+		assert.Equal(test, tc.result == Match, parser.IgnoresPath(tc.path), "IgnoresPath disagrees for "+tc.path)
+		assert.Equal(test, tc.result == Negation, parser.IncludesPath(tc.path), "IncludesPath disagrees for "+tc.path)
+	}
+}
+
+// failingReader returns an error once it is read from
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+// Validate "CompileIgnoreReader()"
+func TestCompileIgnoreReader(test *testing.T) {
+	object, error := CompileIgnoreReader(strings.NewReader("# comment\r\n*.log\n\nbuild/\n"))
+	assert.Nil(test, error, "error from CompileIgnoreReader should be nil")
+	assert.Equal(test, []string{"*.log", "build/"}, object.sources, "unexpected patterns")
+	assert.Equal(test, "", object.basePath, "there should be no base path")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+
+	// Without a trailing newline, and with a very long line
+	long := strings.Repeat("a", 200000)
+	object, error = CompileIgnoreReader(strings.NewReader("*.log\n" + long))
+	assert.Nil(test, error, "error from CompileIgnoreReader should be nil")
+	assert.Equal(test, 2, len(object.sources), "the last line should be compiled")
+	assert.Equal(test, len(long), len(object.sources[1]), "the long line should be read whole")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+
+	// A read error midway
+	object, error = CompileIgnoreReader(io.MultiReader(strings.NewReader("*.log\n"), failingReader{}))
+	assert.EqualError(test, error, "connection reset", "the read error should be returned")
+	assert.Nil(test, object, "object should be nil")
+}
+
+// Validate that malformed lines are reported
+func TestCompileIgnoreLines_MalformedLines(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "a**b")
+	assert.Nil(test, object, "object should be nil")
+	assert.NotNil(test, error, "error should not be nil")
+	assert.EqualError(test, error, `line 2: pattern "a**b" has an invalid "**" sequence`, "the error should name the line")
+
+	// Every malformed line is reported
+	object, error = CompileIgnoreLinesWithOptions(Options{StrictGit: true}, "a**b", "*.log", "***", "{a,b}")
+	assert.Nil(test, object, "object should be nil")
+	assert.NotNil(test, error, "error should not be nil")
+	assert.Contains(test, error.Error(), `line 1: pattern "a**b" has an invalid "**" sequence`, "the error should name line 1")
+	assert.Contains(test, error.Error(), `line 3: pattern "***" has an invalid "***" sequence`, "the error should name line 3")
+	assert.Contains(test, error.Error(), `line 4: pattern "{a,b}" uses brace expansion`, "the error should name line 4")
+	assert.NotContains(test, error.Error(), "line 2", "the good line should not be named")
+
+	object, error = CompileIgnoreScanner(bufio.NewScanner(strings.NewReader("a**b\n*.log\n***\n")))
+	assert.Nil(test, object, "object should be nil")
+	assert.NotNil(test, error, "error should not be nil")
+	assert.Contains(test, error.Error(), "line 1: ", "the error should name line 1")
+	assert.Contains(test, error.Error(), "line 3: ", "the error should name line 3")
+}
+
+// Validate "CompileIgnoreLinesScoped()"
+func TestCompileIgnoreLinesScoped(test *testing.T) {
+	object, error := CompileIgnoreLinesScoped("src", "*.log", "/build", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLinesScoped should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("src/a.log"), "src/a.log should match")
+	assert.Equal(test, Match, object.matchesPath("./src/sub/a.log"), "src/sub/a.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a.log"), "a.log should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("lib/a.log"), "lib/a.log should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("srcx/a.log"), "srcx/a.log should not match")
+	assert.Equal(test, Match, object.matchesPath("src/build"), "src/build should match")
+	assert.Equal(test, NonMatch, object.matchesPath("src/sub/build"), "src/sub/build should not match")
+	assert.Equal(test, NonMatch, object.matchesPath("build"), "build should not match")
+	assert.Equal(test, Negation, object.matchesPath("src/keep.log"), "src/keep.log should be re-included")
+
+	object, error = CompileIgnoreLinesScoped("./a/b/", "*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLinesScoped should be nil")
+	assert.Equal(test, Match, object.matchesPath("a/b/c.log"), "a/b/c.log should match")
+	assert.Equal(test, NonMatch, object.matchesPath("a/c.log"), "a/c.log should not match")
+
+	object, error = CompileIgnoreLinesScoped(".", "*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLinesScoped should be nil")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "the current directory should not restrict the patterns")
+
+	_, error = CompileIgnoreLinesScoped("../up", "*.log")
+	assert.NotNil(test, error, "a scope outside of the base should be rejected")
+	_, error = CompileIgnoreLinesScoped("/abs", "*.log")
+	assert.NotNil(test, error, "an absolute scope should be rejected")
+	_, error = CompileIgnoreLinesScoped("src", "a**b")
+	assert.NotNil(test, error, "malformed lines should be rejected")
+}
+
+// Validate "CompileIgnoreLinesWithError()"
+func TestCompileIgnoreLinesWithError(test *testing.T) {
+	object, errs := CompileIgnoreLinesWithError("# comment", "***", "*.log", "", "a**b", "!keep.log")
+	assert.NotNil(test, object, "object should not be nil")
+	assert.Equal(test, 2, len(errs), "the two malformed lines should be reported")
+	assert.Equal(test, 2, errs[0].LineNumber, "line numbers should be 1-based")
+	assert.Equal(test, "***", errs[0].Text, "the line should be reported as given")
+	assert.EqualError(test, errs[0].Err, `pattern "***" has an invalid "***" sequence`, "unexpected error")
+	assert.Equal(test, 5, errs[1].LineNumber, "line numbers should be 1-based")
+	assert.Equal(test, "a**b", errs[1].Text, "the line should be reported as given")
+	assert.EqualError(test, errs[1], `line 5: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+
+	// The good lines still apply
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+	assert.Equal(test, Negation, object.matchesPath("keep.log"), "keep.log should be re-included")
+	assert.Equal(test, NonMatch, object.matchesPath("a.txt"), "a.txt should not match")
+
+	object, errs = CompileIgnoreLinesWithError("*.log")
+	assert.Nil(test, errs, "there should be no errors")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+}
+
+// Validate that the descendant suffix matches exactly what "(|/.+)$" did
+func TestDescendantSuffix(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "/build", "a/**/b", "**/tmp", "src/*.go", "dir/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	paths := []string{
+		"a.log", "x/a.log", "a.log/x", "a.logx", "build", "build/", "build/x/y", "sub/build",
+		"a/b", "a/x/b/c", "tmp", "x/tmp/y", "src/main.go", "src/x/main.go", "dir", "dir/x", "dirx",
+		"a.log//x", strings.Repeat("d/", 50) + "a.log",
+	}
+	for _, lazy := range object.patterns {
+		pattern := lazy.get()
+		expr := strings.TrimSuffix(pattern.String(), descendantSuffix)
+		former := regexp.MustCompile(expr + "(|/.+)$")
+		for _, f := range paths {
+			assert.Equal(test, former.MatchString(f), pattern.MatchString(f), "unexpected result for "+f+" with "+pattern.String())
+		}
+	}
+}
+
+// Benchmark matching a long path which only the last pattern targets
+func BenchmarkMatchesPath_LongPath(bench *testing.B) {
+	object, _ := CompileIgnoreLines("*.log", "build/", "**/tmp", "/vendor", "src/*.go")
+	f := strings.Repeat("segment/", 500) + "tmp/file.txt"
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		object.matchesPath(f)
+	}
+}
+
+// Benchmark parsing a large ignore file
+func BenchmarkCompileIgnoreLines(bench *testing.B) {
+	lines := make([]string, 0, 5000)
+	for i := 0; len(lines) < cap(lines); i++ {
+		lines = append(lines, "# section "+fmt.Sprint(i), fmt.Sprintf("*.log%d", i), fmt.Sprintf("/build%d/", i),
+			fmt.Sprintf("**/tmp%d/**", i), fmt.Sprintf("!src/keep%d.txt", i))
+	}
+	bench.ReportAllocs()
+	bench.ResetTimer()
+	for i := 0; i < bench.N; i++ {
+		CompileIgnoreLines(lines...)
+	}
+}
+
+// Validate "MatchesPathIsDir()" with directory-only patterns [Rule 5]
+func TestMatchesPathIsDir(test *testing.T) {
+	object, error := CompileIgnoreLines("build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.True(test, object.MatchesPathIsDir("build", true), "the build directory should match")
+	assert.False(test, object.MatchesPathIsDir("build", false), "the build file should not match")
+	assert.True(test, object.MatchesPathIsDir("build/x.o", false), "build/x.o should match through build")
+	assert.True(test, object.MatchesPathIsDir("src/build/x.o", false), "src/build/x.o should match through src/build")
+	assert.True(test, object.MatchesPathIsDir("build/", false), "a trailing slash marks a directory")
+	assert.False(test, object.MatchesPathIsDir("buildx", true), "buildx should not match")
+	assert.Equal(test, []bool{true}, object.dirOnly, "the pattern should be directory-only")
+}
+
+// Validate "MatchesPathIsDir()" for every combination of a trailing slash
+// and "isDir", with and without a directory-only pattern [Rule 5]
+func TestMatchesPathIsDir_TrailingSlash(test *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		isDir   bool
+		matches bool
+	}{
+		{"build", "build", false, true},
+		{"build", "build", true, true},
+		{"build", "build/", false, true},
+		{"build", "build/", true, true},
+		{"build/", "build", false, false},
+		{"build/", "build", true, true},
+		{"build/", "build/", false, true}, // The trailing slash marks a directory
+		{"build/", "build/", true, true},
+	}
+	for _, t := range tests {
+		object, error := CompileIgnoreLines(t.pattern)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		assert.Equal(test, t.matches, object.MatchesPathIsDir(t.path, t.isDir), fmt.Sprintf("unexpected result for %q with %q, isDir %v", t.path, t.pattern, t.isDir))
+	}
 }
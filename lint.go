@@ -0,0 +1,55 @@
+package ignore
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// LintWarning is an advisory finding about a line of an ignore file
+type LintWarning struct {
+	LineNumber int    // 1-based number of the offending line
+	Text       string // The line as it was given
+	Message    string // What is likely wrong and how to fix it
+}
+
+// commonDirectories lists names which are nearly always directories
+var commonDirectories = map[string]bool{
+	"node_modules":     true,
+	"bower_components": true,
+	"vendor":           true,
+	"build":            true,
+	"dist":             true,
+	"target":           true,
+	"bin":              true,
+	"obj":              true,
+	"out":              true,
+	"coverage":         true,
+	"__pycache__":      true,
+	".venv":            true,
+	"venv":             true,
+	".idea":            true,
+	".vscode":          true,
+}
+
+// Lint checks the lines of an ignore file for likely mistakes. It never
+// fails; every finding is advisory. Currently it flags patterns naming a
+// common directory, such as "node_modules", without a trailing slash,
+// since these also match a regular file of that name.
+func Lint(lines ...string) []LintWarning {
+	var warnings []LintWarning
+	for idx, line := range lines {
+		pattern := trimLine(line)
+		if pattern == "" || strings.HasPrefix(pattern, "#") || strings.HasSuffix(pattern, "/") {
+			continue
+		}
+		if commonDirectories[path.Base(strings.TrimPrefix(pattern, "!"))] {
+			warnings = append(warnings, LintWarning{
+				LineNumber: idx + 1,
+				Text:       line,
+				Message:    fmt.Sprintf("%q also matches files, use %q to match directories only", pattern, pattern+"/"),
+			})
+		}
+	}
+	return warnings
+}
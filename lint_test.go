@@ -0,0 +1,20 @@
+// Implement tests for the linter of the `ignore` library
+package ignore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate the directory-only check of "Lint()"
+func TestLint_DirectoryWithoutSlash(test *testing.T) {
+	warnings := Lint("node_modules", "node_modules/", "# node_modules", "!/src/vendor", "*.log")
+	assert.Equal(test, 2, len(warnings), "should have 2 warnings")
+
+	assert.Equal(test, 1, warnings[0].LineNumber, "node_modules should be flagged")
+	assert.Equal(test, "node_modules", warnings[0].Text, "unexpected text")
+	assert.Contains(test, warnings[0].Message, `"node_modules/"`, "the message should suggest a trailing slash")
+
+	assert.Equal(test, 4, warnings[1].LineNumber, "!/src/vendor should be flagged")
+}
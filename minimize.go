@@ -0,0 +1,159 @@
+package ignore
+
+import (
+	"strings"
+)
+
+// Minimize returns an equivalent GitIgnore without redundant patterns. It
+// drops exact duplicates and literal patterns, such as "debug.log", which
+// are shadowed by a broader one, such as "*.log". The analysis is
+// conservative, and the result is checked against the original on a
+// corpus of paths generated from the patterns. Should the two ever
+// disagree, an unchanged copy is returned instead.
+func (g *GitIgnore) Minimize() *GitIgnore {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	keep := make([]bool, len(g.patterns))
+	for idx := len(keep) - 1; idx >= 0; idx-- {
+		keep[idx] = !g.shadowed(idx, keep)
+	}
+
+	m := g.subset(keep)
+	for _, f := range g.sampleCorpus() {
+		for _, isDir := range []bool{false, true} {
+			if result, _ := g.evaluateLocked(f, isDir, nil); result != m.matchesRelPath(f, isDir) {
+				return g.subset(nil)
+			}
+		}
+	}
+	return m
+}
+
+// subset returns a copy of g holding only the patterns flagged in "keep",
+// or all of them if "keep" is nil
+func (g *GitIgnore) subset(keep []bool) *GitIgnore {
+	s := &GitIgnore{basePath: g.basePath, opts: g.opts}
+	for idx := range g.patterns {
+		if keep == nil || keep[idx] {
+			s.appendRule(g, idx)
+		}
+	}
+	return s
+}
+
+// shadowed returns true if removing the pattern at "idx" cannot change the
+// result for any path, given the patterns after it which are kept
+func (g *GitIgnore) shadowed(idx int, keep []bool) bool {
+	// A later pattern covering the same paths always overrides this one.
+	// A positive pattern must be covered by a positive one though, as it
+	// may be what a later negation re-includes from.
+	for j := idx + 1; j < len(keep); j++ {
+		if keep[j] && (g.negate[idx] || !g.negate[j]) && g.covers(j, idx) {
+			return true
+		}
+	}
+	if g.negate[idx] {
+		return false
+	}
+
+	// An earlier positive pattern covering the same paths has already
+	// ignored them, unless a negation in between re-included some
+	for j := idx - 1; j >= 0 && !g.negate[j]; j-- {
+		if g.covers(j, idx) {
+			return true
+		}
+	}
+	return false
+}
+
+// literalBody returns the path a pattern names if it has no wildcards
+func literalBody(source string) (string, bool) {
+	body := strings.TrimPrefix(source, "!")
+	body = strings.TrimPrefix(strings.TrimSuffix(body, "/"), "/")
+	if body == "" || strings.ContainsAny(body, `*?[]\$`) {
+		return "", false
+	}
+	return body, true
+}
+
+// covers returns true if the pattern at "j" targets every path which the
+// pattern at "idx" targets. This is only decided for identical patterns,
+// and for literal ones by probing the paths they can target. Patterns of
+// nested sets only cover those of the same directory.
+func (g *GitIgnore) covers(j, idx int) bool {
+	if g.dirs[j] != g.dirs[idx] {
+		return false
+	}
+	if strings.TrimPrefix(g.sources[j], "!") == strings.TrimPrefix(g.sources[idx], "!") {
+		return true
+	}
+	body, ok := literalBody(g.sources[idx])
+	if !ok || (g.dirOnly[j] && !g.dirOnly[idx]) {
+		return false
+	}
+
+	probed := false
+	if dir := g.dirs[idx]; dir != "" {
+		body = dir + "/" + body
+	}
+	for _, f := range []string{body, "x/" + body, body + "/x"} {
+		for _, isDir := range []bool{false, true} {
+			if !g.ruleMatches(idx, f, isDir, nil) {
+				continue
+			}
+			if !g.ruleMatches(j, f, isDir, nil) {
+				return false
+			}
+			probed = true
+		}
+	}
+	return probed
+}
+
+// sampleCorpus generates paths exercising the patterns of g, with their
+// wildcards replaced by literal characters, at several depths
+func (g *GitIgnore) sampleCorpus() []string {
+	wildcards := strings.NewReplacer("**", "x/y", "*", "x", "?", "x", "[", "", "]", "", `\`, "")
+	var corpus []string
+	for idx, source := range g.sources {
+		body := strings.TrimPrefix(source, "!")
+		body = strings.TrimPrefix(strings.TrimSuffix(body, "/"), "/")
+		body = wildcards.Replace(body)
+		if dir := g.dirs[idx]; dir != "" {
+			body = dir + "/" + body
+		}
+		corpus = append(corpus, body, "d/"+body, body+"/f", "d/"+body+"/f")
+	}
+	return corpus
+}
+
+// CoversPattern returns true if every path the given pattern would ignore
+// is already ignored by g, so that adding it would be redundant. This is a
+// heuristic: pattern is compiled with the options of g, and its matches
+// among the paths generated from it, with wildcards replaced by literal
+// characters, must all be ignored by g. Negations, blank lines and invalid
+// patterns are never covered.
+func (g *GitIgnore) CoversPattern(pattern string) bool {
+	p := &GitIgnore{basePath: g.basePath, opts: g.opts}
+	if added, err := p.addLine(pattern, "", 0); !added || err != nil || p.negate[0] {
+		return false
+	}
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	probed := false
+	for _, f := range p.sampleCorpus() {
+		for _, isDir := range []bool{false, true} {
+			if !p.ruleMatches(0, f, isDir, nil) {
+				continue
+			}
+			if result, _ := g.evaluateLocked(f, isDir, nil); result != Match {
+				return false
+			}
+			probed = true
+		}
+	}
+	return probed
+}
@@ -0,0 +1,56 @@
+// Implement tests for the pattern minimization of the `ignore` library
+package ignore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate "Minimize()"
+func TestMinimize(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "debug.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	minimized := object.Minimize()
+	assert.Equal(test, []string{"*.log"}, minimized.sources, "debug.log should be removed")
+	for _, f := range []string{"debug.log", "a/debug.log", "a.log", "a.txt", "debug.log/x"} {
+		assert.Equal(test, object.matchesPath(f), minimized.matchesPath(f), "unexpected result for "+f)
+	}
+
+	// Duplicates are removed, and the later copy of a negation is kept
+	object, error = CompileIgnoreLines("build/", "!keep.log", "*.tmp", "build/", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{"*.tmp", "build/", "!keep.log"}, object.Minimize().sources, "duplicates should be removed")
+}
+
+// Validate that "Minimize()" preserves patterns a negation depends on
+func TestMinimize_KeepsPatternsAroundNegations(test *testing.T) {
+	// The negation never decides, but debug.log has to ignore again after it
+	object, error := CompileIgnoreLines("*.log", "!debug.log", "debug.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{"*.log", "debug.log"}, object.Minimize().sources, "only the negation should be removed")
+
+	object, error = CompileIgnoreLines("debug.log", "!*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, object.sources, object.Minimize().sources, "no pattern should be removed")
+	assert.Equal(test, Negation, object.Minimize().matchesPath("debug.log"), "debug.log should be re-included")
+}
+
+// Validate "CoversPattern()"
+func TestCoversPattern(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "build/", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	assert.True(test, object.CoversPattern("debug.log"), "debug.log should be covered by *.log")
+	assert.True(test, object.CoversPattern("/logs/*.log"), "/logs/*.log should be covered by *.log")
+	assert.True(test, object.CoversPattern("build/"), "build/ should be covered by itself")
+
+	assert.False(test, object.CoversPattern("*.txt"), "*.txt should not be covered")
+	assert.False(test, object.CoversPattern("debug.*"), "debug.* should not be covered")
+	assert.False(test, object.CoversPattern("build"), "the build file should not be covered")
+	assert.False(test, object.CoversPattern("keep.log"), "keep.log is re-included")
+	assert.False(test, object.CoversPattern("!debug.log"), "negations should not be covered")
+	assert.False(test, object.CoversPattern("# debug.log"), "comments should not be covered")
+	assert.False(test, object.CoversPattern("a**b"), "invalid patterns should not be covered")
+}
@@ -0,0 +1,188 @@
+package ignore
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Rule is a single ignore pattern together with its provenance
+type Rule struct {
+	Pattern string // The pattern text as it would appear in an ignore file
+	Source  string // Optional label describing where the pattern came from
+	Negate  bool   // Re-include matching paths, same as a leading "!"
+	DirOnly bool   // Only match directories, same as a trailing "/"
+}
+
+// line returns the ignore file line for r, applying its flags
+func (r Rule) line() string {
+	line := r.Pattern
+	if r.Negate && !strings.HasPrefix(line, "!") {
+		line = "!" + line
+	}
+	if r.DirOnly && !strings.HasSuffix(line, "/") {
+		line = line + "/"
+	}
+	return line
+}
+
+// AddRule compiles r with the options of g and appends it after the
+// existing rules, so that it takes precedence over them. Blank patterns and
+// comments are rejected.
+func (g *GitIgnore) AddRule(r Rule) error {
+	g.mu.Lock()
+	added, err := g.addLine(r.line(), r.Source, 0)
+	g.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !added {
+		return errors.New("rule has no pattern")
+	}
+	return nil
+}
+
+// AddPattern compiles an ignore file line with the options of g and
+// appends it after the existing rules, so that it takes precedence over
+// them. Blank lines and comments add nothing. Like all methods of g it may
+// be called concurrently with matching, which sees the rules either before
+// or after the addition.
+func (g *GitIgnore) AddPattern(line string) error {
+	return g.AddPatterns(line)
+}
+
+// AddPatterns works like AddPattern for several lines. Either all of them
+// are added, or none if one is malformed; the error names every malformed
+// line with its 1-based number among "lines".
+func (g *GitIgnore) AddPatterns(lines ...string) error {
+	g.mu.RLock()
+	opts := g.opts
+	g.mu.RUnlock()
+
+	added, errs := compileLines(opts, lines)
+	if errs != nil {
+		return lineErrors(errs)
+	}
+	for idx := range added.lineNums {
+		added.lineNums[idx] = 0
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.appendRules(added, "")
+	return nil
+}
+
+// UpdateLine replaces the line at the 0-based "index" of the lines g was
+// compiled from with "newLine", recompiling only that line. The rule keeps
+// its place in the order of precedence. A pattern written over a blank line
+// or a comment is inserted among the rules of the lines around it, and a
+// blank line or comment written over a pattern removes its rule. Rules
+// added with AddRule stay last. This is meant for a set compiled from a
+// single list of lines, such as an ignore file being edited. On error g is
+// left unchanged.
+func (g *GitIgnore) UpdateLine(index int, newLine string) error {
+	if index < 0 {
+		return fmt.Errorf("invalid line index %d", index)
+	}
+	lineNum := index + 1
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	label := ""
+	for idx, n := range g.lineNums {
+		if n == lineNum {
+			label = g.labels[idx]
+		}
+	}
+	compiled := &GitIgnore{opts: g.opts}
+	if _, err := compiled.addLine(newLine, label, lineNum); err != nil {
+		return fmt.Errorf("line %d: %v", lineNum, err)
+	}
+
+	// Rebuild the rule list around the one line, the other patterns are
+	// not compiled again
+	res := &GitIgnore{}
+	inserted := false
+	for idx, n := range g.lineNums {
+		if !inserted && (n > lineNum || n == 0) {
+			res.appendRules(compiled, "")
+			inserted = true
+		}
+		if n != lineNum {
+			res.appendRule(g, idx)
+		}
+	}
+	if !inserted {
+		res.appendRules(compiled, "")
+	}
+	g.replaceRules(res)
+	return nil
+}
+
+// Rules returns the rules of g in order of precedence, lowest first
+func (g *GitIgnore) Rules() []Rule {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	rules := make([]Rule, len(g.sources))
+	for idx, source := range g.sources {
+		rules[idx] = Rule{
+			Pattern: source,
+			Source:  g.labels[idx],
+			Negate:  g.negate[idx],
+			DirOnly: g.dirOnly[idx],
+		}
+	}
+	return rules
+}
+
+// Patterns returns the source lines of the patterns of g in order of
+// precedence, lowest first. Blank lines and comments produce no pattern and
+// are not listed.
+func (g *GitIgnore) Patterns() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string(nil), g.sources...)
+}
+
+// String implements fmt.Stringer, returning the rules of g as an ignore
+// file of NormalizedRules, one per line. Compiling its lines with the
+// options of g gives a set which matches the same paths relative to the
+// base path. The defaults of the ConvertFrom dialect are not rules and are
+// not written.
+func (g *GitIgnore) String() string {
+	return strings.Join(g.NormalizedRules(), "\n")
+}
+
+// Hash returns an FNV-1a hash of the base path, the options and the
+// ordered rules of g with the directories they apply to, which is stable
+// across runs and can key a cache of compiled sets. Sets compiled from the
+// same lines with the same options against the same base path hash
+// equally. The EnvMapping and NormalizeUnicode functions only count by
+// whether they are set, and Lazy, which does not change the results, not
+// at all.
+func (g *GitIgnore) Hash() uint64 {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	h := fnv.New64a()
+	h.Write([]byte(g.basePath))
+	o := g.opts
+	fmt.Fprintf(h, "\x00%t %q %t %t %t %t %d %t %t", o.StrictGit, o.RegexpFlags, o.CaseInsensitive,
+		o.AnchorEnd, o.ExpandEnv, o.EnvMapping != nil, o.ConvertFrom, o.NormalizeUnicode != nil, o.BangBang)
+	for idx, source := range g.sources {
+		// The NUL separators keep "ab" from hashing like "a" and "b"
+		flag := byte('+')
+		if g.negate[idx] {
+			flag = '!'
+		}
+		h.Write([]byte{0, flag})
+		h.Write([]byte(source))
+		h.Write([]byte{0})
+		h.Write([]byte(g.dirs[idx]))
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,222 @@
+// Implement tests for the structured rules of the `ignore` library
+package ignore
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Validate "AddRule()" and the "Rules()" accessor
+func TestAddRule(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	error = object.AddRule(Rule{Pattern: "debug.log", Source: "generator", Negate: true})
+	assert.Nil(test, error, "error from AddRule should be nil")
+	error = object.AddRule(Rule{Pattern: "build", Source: "generator", DirOnly: true})
+	assert.Nil(test, error, "error from AddRule should be nil")
+
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should match")
+	assert.Equal(test, Negation, object.matchesPath("debug.log"), "debug.log should be negated")
+	assert.Equal(test, Match, object.matchesPath("build/"), "build/ should match")
+	assert.Equal(test, NonMatch, object.matchesPath("build"), "the build file should not match")
+
+	assert.Equal(test, []Rule{
+		{Pattern: "*.log"},
+		{Pattern: "!debug.log", Source: "generator", Negate: true},
+		{Pattern: "build/", Source: "generator", DirOnly: true},
+	}, object.Rules(), "unexpected rules")
+
+	// Rules survive a round trip and a merge
+	copied, _ := CompileIgnoreLines()
+	for _, rule := range object.Rules() {
+		assert.Nil(test, copied.AddRule(rule), "error from AddRule should be nil")
+	}
+	assert.Equal(test, object.Rules(), copied.Rules(), "round trip should keep the rules")
+	assert.Equal(test, object.Rules(), Merge(object).Rules(), "merge should keep the labels")
+}
+
+// Validate that "AddRule()" rejects patterns that cannot be compiled
+func TestAddRule_Invalid(test *testing.T) {
+	object, _ := CompileIgnoreLinesWithOptions(Options{StrictGit: true})
+
+	assert.NotNil(test, object.AddRule(Rule{Pattern: "   "}), "blank patterns should be rejected")
+	assert.NotNil(test, object.AddRule(Rule{Pattern: "# comment"}), "comments should be rejected")
+	assert.NotNil(test, object.AddRule(Rule{Pattern: "a**b"}), "bad double stars should be rejected")
+	assert.NotNil(test, object.AddRule(Rule{Pattern: "{a,b}"}), "braces should be rejected in strict mode")
+	error := object.AddRule(Rule{Pattern: "foo***"})
+	assert.NotNil(test, error, "patterns which cannot be compiled should be rejected")
+	assert.Contains(test, error.Error(), `pattern "foo***" has an invalid "***" sequence`, "the error should name the pattern")
+	assert.Equal(test, 0, len(object.Rules()), "nothing should have been added")
+}
+
+// Validate "AddPattern()" and "AddPatterns()"
+func TestAddPattern(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("build/x.o"), "build/x.o should not match yet")
+
+	assert.Nil(test, object.AddPattern("build/"), "error from AddPattern should be nil")
+	assert.Equal(test, Match, object.matchesPath("build/x.o"), "build/x.o should match")
+
+	// A negation added afterwards flips the result
+	assert.Nil(test, object.AddPattern("!build/x.o"), "error from AddPattern should be nil")
+	assert.Equal(test, Negation, object.matchesPath("build/x.o"), "build/x.o should be re-included")
+
+	assert.Nil(test, object.AddPatterns("# user preferences", "", "*.tmp", "!keep.tmp"), "error from AddPatterns should be nil")
+	assert.Equal(test, Match, object.matchesPath("a.tmp"), "a.tmp should match")
+	assert.Equal(test, Negation, object.matchesPath("keep.tmp"), "keep.tmp should be re-included")
+	assert.Equal(test, []string{"*.log", "build/", "!build/x.o", "*.tmp", "!keep.tmp"}, object.sources, "the patterns should be appended in order")
+
+	// Malformed lines add nothing
+	error = object.AddPatterns("*.bak", "a**b")
+	assert.EqualError(test, error, `line 2: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+	assert.NotNil(test, object.AddPattern("***"), "a malformed line should be rejected")
+	assert.Equal(test, NonMatch, object.matchesPath("a.bak"), "a.bak should not match")
+	assert.Equal(test, 5, len(object.patterns), "nothing should have been added")
+
+	// The options of g apply
+	object, _ = CompileIgnoreLinesCaseInsensitive()
+	assert.Nil(test, object.AddPattern("*.PNG"), "error from AddPattern should be nil")
+	assert.Equal(test, Match, object.matchesPath("a.png"), "a.png should match")
+}
+
+// Validate adding patterns while the rules are in use, run with -race
+func TestAddPattern_Concurrent(test *testing.T) {
+	object, _ := CompileIgnoreLines("*.log")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			object.matchesPath("build/a.log")
+			object.MatchesPathHow("build/a.log")
+			object.Rules()
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		assert.Nil(test, object.AddPattern(fmt.Sprintf("*.tmp%d", i)), "error from AddPattern should be nil")
+	}
+	<-done
+	assert.Equal(test, 101, len(object.Rules()), "every pattern should have been added")
+}
+
+// Validate "Patterns()" and the round trip through "String()"
+func TestString(test *testing.T) {
+	lines := []string{
+		"# build output",
+		"build/",
+		"*.log",
+		"",
+		"!keep.log",
+		"docs/*.html",
+		"/dist",
+		"**/tmp/**",
+		`\#notes`,
+		`!\!important`,
+		"*.log",
+	}
+	object, error := CompileIgnoreLines(lines...)
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Equal(test, []string{"build/", "*.log", "!keep.log", "docs/*.html", "/dist", "**/tmp/**", `\#notes`, `!\!important`, "*.log"}, object.Patterns(), "unexpected patterns")
+	assert.Equal(test, "build/\n!keep.log\n/docs/*.html\n/dist\n**/tmp/**\n\\#notes\n!\\!important\n*.log", object.String(), "unexpected ignore file")
+
+	// A nested set is written anchored below its directory
+	scoped, _ := CompileIgnoreLinesScoped("sub", "*.o", "!/main.o")
+	merged := Merge(object, scoped)
+
+	paths := []string{
+		"build/", "build/x", "a.log", "keep.log", "src/keep.log", "docs/a.html", "docs/x/a.html", "dist", "src/dist",
+		"tmp/x", "a/tmp/b/c", "#notes", "!important", "x.o", "sub/x.o", "sub/main.o", "sub/y/main.o",
+	}
+	for _, set := range []*GitIgnore{object, merged} {
+		copied, error := CompileIgnoreLines(strings.Split(set.String(), "\n")...)
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		for _, f := range paths {
+			assert.Equal(test, set.matchesPath(f), copied.matchesPath(f), "unexpected result for "+f+" after a round trip")
+		}
+	}
+	assert.Equal(test, fmt.Sprint(merged), merged.String(), "String should implement fmt.Stringer")
+
+	empty, _ := CompileIgnoreLines()
+	assert.Equal(test, "", empty.String(), "an empty set should be an empty file")
+	assert.Nil(test, empty.Patterns(), "an empty set should have no patterns")
+}
+
+// Validate "Hash()"
+func TestHash(test *testing.T) {
+	a, _ := CompileIgnoreLines("*.log", "!debug.log", "build/")
+	b, _ := CompileIgnoreLines("*.log", "# comment", "!debug.log", "", "build/")
+	assert.Equal(test, a.Hash(), b.Hash(), "identical rule sets should hash equally")
+
+	for _, lines := range [][]string{
+		{"*.log", "!debug.log"},
+		{"!debug.log", "*.log", "build/"},
+		{"*.log", "!debug.log", "build"},
+		{"*.lo", "g!debug.log", "build/"},
+		{"*.log", "!debug.log", "build/", "x"},
+	} {
+		other, _ := CompileIgnoreLines(lines...)
+		assert.NotEqual(test, a.Hash(), other.Hash(), fmt.Sprintf("%q should hash differently", lines))
+	}
+
+	// The base path is part of the hash
+	other, _ := CompileIgnoreLines("*.log", "!debug.log", "build/")
+	other.basePath = "sub"
+	assert.NotEqual(test, a.Hash(), other.Hash(), "a different base path should hash differently")
+	empty, _ := CompileIgnoreLines()
+	assert.NotEqual(test, a.Hash(), empty.Hash(), "an empty set should hash differently")
+
+	// So are the options and the directories of the rules
+	plain, _ := CompileIgnoreLines("*.PNG")
+	folded, _ := CompileIgnoreLinesCaseInsensitive("*.PNG")
+	assert.NotEqual(test, plain.Hash(), folded.Hash(), "a case-insensitive set should hash differently")
+	for _, opts := range []Options{{StrictGit: true}, {RegexpFlags: "(?s)"}, {AnchorEnd: true}, {ExpandEnv: true}, {ConvertFrom: Docker}, {BangBang: true}} {
+		other, _ := CompileIgnoreLinesWithOptions(opts, "*.PNG")
+		assert.NotEqual(test, plain.Hash(), other.Hash(), fmt.Sprintf("%+v should hash differently", opts))
+	}
+	lazy, _ := CompileIgnoreLinesWithOptions(Options{Lazy: true}, "*.PNG")
+	assert.Equal(test, plain.Hash(), lazy.Hash(), "compiling lazily should not change the hash")
+
+	scoped, _ := CompileIgnoreLinesScoped("src", "*.log")
+	unscoped, _ := CompileIgnoreLines("*.log")
+	assert.NotEqual(test, scoped.Hash(), unscoped.Hash(), "a scoped set should hash differently")
+}
+
+// Validate "UpdateLine()"
+func TestUpdateLine(test *testing.T) {
+	object, error := CompileIgnoreLines("*.log", "# comment", "build/", "", "!keep.log")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	assert.Nil(test, object.AddRule(Rule{Pattern: "*.tmp", Source: "generator"}), "error from AddRule should be nil")
+
+	// Replacing a pattern only changes the behaviour of that rule
+	assert.Nil(test, object.UpdateLine(2, "dist/"), "error from UpdateLine should be nil")
+	assert.Equal(test, NonMatch, object.matchesPath("build/"), "build/ should no longer match")
+	assert.Equal(test, Match, object.matchesPath("dist/"), "dist/ should match")
+	assert.Equal(test, Match, object.matchesPath("a.log"), "a.log should still match")
+	assert.Equal(test, Negation, object.matchesPath("keep.log"), "keep.log should still be re-included")
+	assert.Equal(test, []string{"*.log", "dist/", "!keep.log", "*.tmp"}, object.sources, "the order should be kept")
+
+	// A pattern over a comment is inserted in place
+	assert.Nil(test, object.UpdateLine(1, "!debug.log"), "error from UpdateLine should be nil")
+	assert.Equal(test, Negation, object.matchesPath("debug.log"), "debug.log should be re-included")
+	assert.Equal(test, []string{"*.log", "!debug.log", "dist/", "!keep.log", "*.tmp"}, object.sources, "the pattern should be inserted")
+
+	// A comment over a pattern removes its rule
+	assert.Nil(test, object.UpdateLine(4, "# keep.log is ignored too"), "error from UpdateLine should be nil")
+	assert.Equal(test, Match, object.matchesPath("keep.log"), "keep.log should match")
+	assert.Equal(test, []string{"*.log", "!debug.log", "dist/", "*.tmp"}, object.sources, "the pattern should be removed")
+
+	// Lines past the end are appended before the added rules
+	assert.Nil(test, object.UpdateLine(7, "out/"), "error from UpdateLine should be nil")
+	assert.Equal(test, []string{"*.log", "!debug.log", "dist/", "out/", "*.tmp"}, object.sources, "the pattern should be appended")
+	assert.Equal(test, "generator", object.Rules()[4].Source, "the added rule should keep its label")
+
+	// Malformed lines leave the rules alone
+	assert.EqualError(test, object.UpdateLine(0, "a**b"), `line 1: pattern "a**b" has an invalid "**" sequence`, "unexpected error")
+	assert.NotNil(test, object.UpdateLine(-1, "*.log"), "a negative index should be rejected")
+	assert.Equal(test, []string{"*.log", "!debug.log", "dist/", "out/", "*.tmp"}, object.sources, "the rules should be unchanged")
+}
@@ -0,0 +1,164 @@
+package ignore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkBase returns the directory against which paths found while walking
+// "root" are matched. A GitIgnore without a base path of its own is taken
+// to apply at the root of the walk.
+func (g *GitIgnore) walkBase(root string) string {
+	if g.basePath == "" {
+		return root
+	}
+	return g.basePath
+}
+
+// WalkAndReport walks the file tree rooted at "root" and sorts every entry
+// below it into "kept" or "pruned". Ignored directories are reported in
+// "pruned" and are not descended into, so their contents appear in neither
+// slice. Re-included paths are kept.
+func (g *GitIgnore) WalkAndReport(root string) (kept []string, pruned []string, err error) {
+	base := g.walkBase(root)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if g.matchesRelPath(relativePath(base, path), info.IsDir()) != Match {
+			kept = append(kept, path)
+			return nil
+		}
+		pruned = append(pruned, path)
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return kept, pruned, err
+}
+
+// WalkIncluded walks the file tree rooted at "root" like filepath.Walk, but
+// calls "fn" only for the entries below it which are not ignored. Ignored
+// directories are not descended into, so "fn" never sees their contents.
+// An error returned by "fn", including filepath.SkipDir, is handled as by
+// filepath.Walk, and an error walking the tree is returned as is.
+func (g *GitIgnore) WalkIncluded(root string, fn func(path string, info os.FileInfo) error) error {
+	base := g.walkBase(root)
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		if g.matchesRelPath(relativePath(base, path), info.IsDir()) != Match {
+			return fn(path, info)
+		}
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+// IgnoreNode is a node of the tree returned by BuildIgnoreTree
+type IgnoreNode struct {
+	Path     string        // Path of the file or directory
+	IsDir    bool          // Whether the path is a directory
+	Ignored  bool          // Whether the path is ignored
+	Pruned   bool          // Whether this is an ignored directory which was not walked
+	Children []*IgnoreNode // Entries of a walked directory, sorted by name
+}
+
+// BuildIgnoreTree walks the file tree rooted at "root" and returns it as a
+// tree of nodes recording whether each path is ignored. Ignored directories
+// are marked as pruned and have no children.
+func (g *GitIgnore) BuildIgnoreTree(root string) (*IgnoreNode, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	node := &IgnoreNode{Path: root, IsDir: info.IsDir()}
+	if err := g.buildIgnoreTree(g.walkBase(root), node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// buildIgnoreTree fills in the children of a directory node recursively
+func (g *GitIgnore) buildIgnoreTree(base string, node *IgnoreNode) error {
+	if !node.IsDir {
+		return nil
+	}
+	infos, err := ioutil.ReadDir(node.Path)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		child := &IgnoreNode{Path: filepath.Join(node.Path, info.Name()), IsDir: info.IsDir()}
+		child.Ignored = g.matchesRelPath(relativePath(base, child.Path), child.IsDir) == Match
+		node.Children = append(node.Children, child)
+		if child.Ignored {
+			child.Pruned = child.IsDir
+			continue
+		}
+		if err := g.buildIgnoreTree(base, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IgnoresExistingPath works like IgnoresPath for a path which exists on
+// disk, telling directories from files by calling os.Lstat so that
+// directory-only patterns apply correctly. A symbolic link is never
+// treated as a directory, as in git. The error of a failed Lstat is
+// returned as is.
+func (g *GitIgnore) IgnoresExistingPath(f string) (bool, error) {
+	info, err := os.Lstat(f)
+	if err != nil {
+		return false, err
+	}
+	rel, _ := g.relPath(f)
+	return g.hierarchicalRelResult(rel, info.IsDir()) == Match, nil
+}
+
+// PlanWalk partitions the candidate directories "dirs", given relative to
+// "root", into those a walk of "root" would descend into and those it would
+// skip. A directory below a skipped one is skipped too, as no negation can
+// re-include it [Rule 4]. The file system is not accessed, and the order of
+// "dirs" is kept.
+func (g *GitIgnore) PlanWalk(root string, dirs []string) (walk []string, skip []string, err error) {
+	base := g.walkBase(root)
+	for _, dir := range dirs {
+		rel := filepath.ToSlash(filepath.Clean(dir))
+		if filepath.IsAbs(dir) || rel == ".." || strings.HasPrefix(rel, "../") {
+			return nil, nil, fmt.Errorf("%s is not below %s", dir, root)
+		}
+		if g.skipsDir(relativePath(base, filepath.Join(root, rel))) {
+			skip = append(skip, dir)
+		} else {
+			walk = append(walk, dir)
+		}
+	}
+	return walk, skip, nil
+}
+
+// skipsDir returns true if the directory "rel", relative to the base path,
+// or any directory holding it is ignored
+func (g *GitIgnore) skipsDir(rel string) bool {
+	segments := strings.Split(rel, "/")
+	for i := 1; i <= len(segments); i++ {
+		if g.matchesRelPath(strings.Join(segments[:i], "/"), true) == Match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+// Implement tests for the tree walking helpers of the `ignore` library
+package ignore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Helper function to create a temporary directory holding the files
+// "files" (slash separated, relative to the directory)
+func makeTestTree(test *testing.T, files ...string) string {
+	root, err := ioutil.TempDir("", "go-git-ignore")
+	if err != nil {
+		test.Fatal(err)
+	}
+	for _, f := range files {
+		fpath := filepath.Join(root, filepath.FromSlash(f))
+		_ = os.MkdirAll(filepath.Dir(fpath), 0755)
+		_ = ioutil.WriteFile(fpath, []byte{}, 0644)
+	}
+	return root
+}
+
+// Validate "WalkAndReport()"
+func TestWalkAndReport(test *testing.T) {
+	root := makeTestTree(test,
+		"a.go", "a.log", "build/x.o", "src/main.go", "src/debug.log", "src/keep.log", "src/build",
+	)
+	defer os.RemoveAll(root)
+
+	object, error := CompileIgnoreLines("*.log", "!keep.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	kept, pruned, error := object.WalkAndReport(root)
+	assert.Nil(test, error, "error from WalkAndReport should be nil")
+
+	join := func(paths ...string) []string {
+		for i, p := range paths {
+			paths[i] = filepath.Join(root, filepath.FromSlash(p))
+		}
+		return paths
+	}
+	assert.Equal(test, join("a.go", "src", "src/build", "src/keep.log", "src/main.go"), kept, "unexpected kept paths")
+	assert.Equal(test, join("a.log", "build", "src/debug.log"), pruned, "unexpected pruned paths")
+}
+
+// Validate that "WalkAndReport()" prunes the children targeted by "build/*"
+func TestWalkAndReport_TrailingStar(test *testing.T) {
+	root := makeTestTree(test, "build/a.o", "build/sub/b.o", "src/build/c.o")
+	defer os.RemoveAll(root)
+
+	object, error := CompileIgnoreLines("build/*")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	kept, pruned, error := object.WalkAndReport(root)
+	assert.Nil(test, error, "error from WalkAndReport should be nil")
+
+	join := func(paths ...string) []string {
+		for i, p := range paths {
+			paths[i] = filepath.Join(root, filepath.FromSlash(p))
+		}
+		return paths
+	}
+	assert.Equal(test, join("build", "src", "src/build", "src/build/c.o"), kept, "unexpected kept paths")
+	assert.Equal(test, join("build/a.o", "build/sub"), pruned, "unexpected pruned paths")
+}
+
+// Validate "WalkIncluded()"
+func TestWalkIncluded(test *testing.T) {
+	root := makeTestTree(test,
+		"a.go", "a.log", "build/x.o", "build/sub/y.o", "src/main.go", "src/debug.log", "src/keep.log", "src/build",
+	)
+	defer os.RemoveAll(root)
+
+	object, err := CompileIgnoreLines("*.log", "!keep.log", "build/", "!build/x.o")
+	assert.Nil(test, err, "error from CompileIgnoreLines should be nil")
+
+	var visited []string
+	err = object.WalkIncluded(root, func(path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		visited = append(visited, filepath.ToSlash(rel))
+		return nil
+	})
+	assert.Nil(test, err, "error from WalkIncluded should be nil")
+	// The build directory is pruned, so build/x.o is never visited
+	assert.Equal(test, []string{"a.go", "src", "src/build", "src/keep.log", "src/main.go"}, visited, "unexpected visited paths")
+
+	// SkipDir from the callback prunes an included directory
+	visited = nil
+	err = object.WalkIncluded(root, func(path string, info os.FileInfo) error {
+		rel, _ := filepath.Rel(root, path)
+		visited = append(visited, filepath.ToSlash(rel))
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	assert.Nil(test, err, "error from WalkIncluded should be nil")
+	assert.Equal(test, []string{"a.go", "src"}, visited, "src should not be descended into")
+
+	// Errors are returned
+	err = object.WalkIncluded(filepath.Join(root, "missing"), func(path string, info os.FileInfo) error {
+		return nil
+	})
+	assert.True(test, os.IsNotExist(err), "a missing root should be an error")
+}
+
+// Validate "BuildIgnoreTree()"
+func TestBuildIgnoreTree(test *testing.T) {
+	root := makeTestTree(test, "a.log", "build/x.o", "src/main.go", "src/debug.log")
+	defer os.RemoveAll(root)
+
+	object, error := CompileIgnoreLines("*.log", "build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	tree, error := object.BuildIgnoreTree(root)
+	assert.Nil(test, error, "error from BuildIgnoreTree should be nil")
+	assert.Equal(test, root, tree.Path, "the root node should be the walked root")
+	assert.False(test, tree.Ignored, "the root should not be ignored")
+	assert.Equal(test, 3, len(tree.Children), "the root should have 3 children")
+
+	aLog, build, src := tree.Children[0], tree.Children[1], tree.Children[2]
+	assert.Equal(test, filepath.Join(root, "a.log"), aLog.Path, "unexpected first child")
+	assert.True(test, aLog.Ignored, "a.log should be ignored")
+	assert.False(test, aLog.Pruned, "a file is never pruned")
+
+	assert.True(test, build.Ignored, "build should be ignored")
+	assert.True(test, build.Pruned, "build should be pruned")
+	assert.Equal(test, 0, len(build.Children), "a pruned directory has no children")
+
+	assert.False(test, src.Ignored, "src should not be ignored")
+	assert.Equal(test, 2, len(src.Children), "src should have 2 children")
+	assert.True(test, src.Children[0].Ignored, "src/debug.log should be ignored")
+	assert.False(test, src.Children[1].Ignored, "src/main.go should not be ignored")
+}
+
+// Validate "IgnoresExistingPath()"
+func TestIgnoresExistingPath(test *testing.T) {
+	root := makeTestTree(test, "build/x.o", "src/build", "src/main.go")
+	defer os.RemoveAll(root)
+
+	object, error := CompileIgnoreLines("build/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	object.basePath = root
+
+	ignored, error := object.IgnoresExistingPath(filepath.Join(root, "build"))
+	assert.Nil(test, error, "error from IgnoresExistingPath should be nil")
+	assert.True(test, ignored, "the directory build should be ignored")
+
+	ignored, error = object.IgnoresExistingPath(filepath.Join(root, "build", "x.o"))
+	assert.Nil(test, error, "error from IgnoresExistingPath should be nil")
+	assert.True(test, ignored, "build/x.o should be ignored")
+
+	ignored, error = object.IgnoresExistingPath(filepath.Join(root, "src", "build"))
+	assert.Nil(test, error, "error from IgnoresExistingPath should be nil")
+	assert.False(test, ignored, "the file src/build should not be ignored")
+
+	// A negation cannot re-include a file of an ignored directory
+	object, error = CompileIgnoreLines("build/", "!build/x.o")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+	object.basePath = root
+	ignored, error = object.IgnoresExistingPath(filepath.Join(root, "build", "x.o"))
+	assert.Nil(test, error, "error from IgnoresExistingPath should be nil")
+	assert.True(test, ignored, "build/x.o should be ignored through build")
+
+	if err := os.Symlink(filepath.Join(root, "build"), filepath.Join(root, "src", "link")); err == nil {
+		object, error = CompileIgnoreLines("link/")
+		assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+		object.basePath = root
+		ignored, error = object.IgnoresExistingPath(filepath.Join(root, "src", "link"))
+		assert.Nil(test, error, "error from IgnoresExistingPath should be nil")
+		assert.False(test, ignored, "a symbolic link is not a directory")
+	}
+
+	_, error = object.IgnoresExistingPath(filepath.Join(root, "missing"))
+	assert.NotNil(test, error, "a missing path should return an error")
+}
+
+// Validate "PlanWalk()"
+func TestPlanWalk(test *testing.T) {
+	object, error := CompileIgnoreLines("node_modules/", "/vendor", "!vendor/keep", "*.tmp/")
+	assert.Nil(test, error, "error from CompileIgnoreLines should be nil")
+
+	walk, skip, error := object.PlanWalk("/repo", []string{"src", "node_modules", "vendor", "src/node_modules", "vendor/keep", "src/x.tmp", "./lib/"})
+	assert.Nil(test, error, "error from PlanWalk should be nil")
+	assert.Equal(test, []string{"src", "./lib/"}, walk, "unexpected directories to walk")
+	assert.Equal(test, []string{"node_modules", "vendor", "src/node_modules", "vendor/keep", "src/x.tmp"}, skip, "unexpected directories to skip")
+
+	for _, dir := range []string{"../other", "/abs", "src/../.."} {
+		_, _, error = object.PlanWalk("/repo", []string{dir})
+		assert.NotNil(test, error, dir+" should be rejected")
+	}
+}